@@ -0,0 +1,255 @@
+package rest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AccessLogFormatCombined = "combined"
+	AccessLogFormatJSON     = "json"
+	AccessLogFormatLogfmt   = "logfmt"
+)
+
+/*
+Access logger settings. When Handler is set the entries are emitted as slog records
+and Format/Output are ignored
+*/
+type AccessLogConfig struct {
+	Format    string            // One of AccessLogFormat*, default AccessLogFormatCombined
+	Output    io.Writer         // Destination of the log lines, default os.Stdout
+	Handler   slog.Handler      // Optional slog handler that receives the entries
+	SkipPaths []string          // Request paths that are not logged, e.g. "/health"
+	ClientIP  *ClientIPResolver // Resolver of the logged client IP, default GetRealIPAddr
+}
+
+/*
+ResponseWriter wrapper that keeps the status code and bytes written to the client
+*/
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func newStatusWriter(w http.ResponseWriter) *statusWriter {
+	return &statusWriter{ResponseWriter: w}
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *statusWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *statusWriter) Written() bool {
+	return w.status != 0
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+type accessLogEntry struct {
+	Time      time.Time
+	RemoteIP  string
+	User      string
+	Method    string
+	URI       string
+	Proto     string
+	Status    int
+	Size      int64
+	Duration  time.Duration
+	Referer   string
+	UserAgent string
+	RequestID string
+}
+
+func newAccessLogEntry(r *http.Request, w *statusWriter, start time.Time, resolver *ClientIPResolver) accessLogEntry {
+	user, _, _ := r.BasicAuth()
+	remoteIP := GetRealIPAddr(r)
+	if resolver != nil {
+		remoteIP = resolver.ClientIP(r)
+	}
+	return accessLogEntry{
+		Time:      start,
+		RemoteIP:  remoteIP,
+		User:      user,
+		Method:    r.Method,
+		URI:       r.RequestURI,
+		Proto:     r.Proto,
+		Status:    w.Status(),
+		Size:      w.size,
+		Duration:  time.Since(start),
+		Referer:   r.Referer(),
+		UserAgent: r.UserAgent(),
+		RequestID: RequestIDFromContext(r.Context()),
+	}
+}
+
+func dashIfEmpty(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func (e accessLogEntry) combined() string {
+	size := "-"
+	if e.Size > 0 {
+		size = strconv.FormatInt(e.Size, 10)
+	}
+	line := fmt.Sprintf(
+		"%s - %s [%s] \"%s %s %s\" %d %s %q %q",
+		dashIfEmpty(e.RemoteIP), dashIfEmpty(e.User), e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		e.Method, e.URI, e.Proto, e.Status, size, dashIfEmpty(e.Referer), dashIfEmpty(e.UserAgent),
+	)
+	if e.RequestID != "" {
+		line += " " + e.RequestID
+	}
+	return line + "\n"
+}
+
+func (e accessLogEntry) fields() []interface{} {
+	return []interface{}{
+		"remote_ip", e.RemoteIP,
+		"user", e.User,
+		"method", e.Method,
+		"uri", e.URI,
+		"proto", e.Proto,
+		"status", e.Status,
+		"bytes", e.Size,
+		"duration_ms", float64(e.Duration.Microseconds()) / 1000,
+		"referer", e.Referer,
+		"user_agent", e.UserAgent,
+		"request_id", e.RequestID,
+	}
+}
+
+func (e accessLogEntry) json() string {
+	fields := append([]interface{}{"time", e.Time.Format(time.RFC3339)}, e.fields()...)
+	data := make(map[string]interface{}, len(fields)/2)
+	for i := 0; i < len(fields); i += 2 {
+		data[fields[i].(string)] = fields[i+1]
+	}
+	encoded, _ := json.Marshal(data)
+	return string(encoded) + "\n"
+}
+
+func (e accessLogEntry) logfmt() string {
+	var sb strings.Builder
+	fields := append([]interface{}{"time", e.Time.Format(time.RFC3339)}, e.fields()...)
+	for i := 0; i < len(fields); i += 2 {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		value := fmt.Sprint(fields[i+1])
+		if value == "" || strings.ContainsAny(value, " =\"") {
+			value = strconv.Quote(value)
+		}
+		sb.WriteString(fields[i].(string) + "=" + value)
+	}
+	sb.WriteByte('\n')
+	return sb.String()
+}
+
+/*
+Create a middleware that logs every request with its response status, bytes written,
+latency and client IP using the configured format and destination
+*/
+func MiddlewareAccessLogger(cnf AccessLogConfig) func(http.Handler) http.Handler {
+	if cnf.Format == "" {
+		cnf.Format = AccessLogFormatCombined
+	}
+	if cnf.Output == nil {
+		cnf.Output = os.Stdout
+	}
+	skip := make(map[string]bool, len(cnf.SkipPaths))
+	for _, path := range cnf.SkipPaths {
+		skip[path] = true
+	}
+
+	var logger *slog.Logger
+	if cnf.Handler != nil {
+		logger = slog.New(cnf.Handler)
+	}
+	var mu sync.Mutex
+
+	write := func(ctx context.Context, entry accessLogEntry) {
+		if logger != nil {
+			logger.InfoContext(ctx, "access", entry.fields()...)
+			return
+		}
+
+		var line string
+		switch cnf.Format {
+		case AccessLogFormatJSON:
+			line = entry.json()
+		case AccessLogFormatLogfmt:
+			line = entry.logfmt()
+		default:
+			line = entry.combined()
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		io.WriteString(cnf.Output, line)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(response http.ResponseWriter, request *http.Request) {
+				if skip[request.URL.Path] {
+					next.ServeHTTP(response, request)
+					return
+				}
+
+				start := time.Now()
+				sw := newStatusWriter(response)
+				next.ServeHTTP(sw, request)
+
+				write(request.Context(), newAccessLogEntry(request, sw, start, cnf.ClientIP))
+			})
+	}
+}
@@ -0,0 +1,83 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestMiddlewareAccessLogger(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		RespondWithJSONMessage(w, http.StatusCreated, "OK")
+	})
+
+	tests := []struct {
+		format string
+		regexp string
+	}{
+		{AccessLogFormatCombined, `^192\.0\.2\.1 - - \[[^\]]+\] "POST /AccessLog\?id=1 HTTP/1\.1" 201 16 "-" "tester"$`},
+		{AccessLogFormatLogfmt, `^time=\S+ remote_ip=192\.0\.2\.1 user="" method=POST uri="/AccessLog\?id=1" proto=HTTP/1\.1 status=201 bytes=16 duration_ms=[0-9.]+ referer="" user_agent=tester request_id=""$`},
+	}
+
+	for _, test := range tests {
+		var out bytes.Buffer
+		middle := MiddlewareAccessLogger(AccessLogConfig{Format: test.format, Output: &out})(handler)
+
+		req := httptest.NewRequest(http.MethodPost, "/AccessLog?id=1", nil)
+		req.Header.Set("User-Agent", "tester")
+		middle.ServeHTTP(httptest.NewRecorder(), req)
+
+		result := strings.TrimSuffix(out.String(), "\n")
+		if match, _ := regexp.MatchString(test.regexp, result); !match {
+			t.Errorf("%s log line do not match regular expresion: \n\t got %v", test.format, result)
+		}
+	}
+
+	var out bytes.Buffer
+	middle := MiddlewareAccessLogger(AccessLogConfig{Format: AccessLogFormatCombined, Output: &out})(handler)
+	req := httptest.NewRequest(http.MethodGet, "/AccessLog", nil)
+	req.SetBasicAuth("gopher", "secret")
+	middle.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !strings.HasPrefix(out.String(), "192.0.2.1 - gopher [") || strings.Contains(out.String(), "secret") {
+		t.Errorf("unexpected basic auth user in log line: \n\t got %v", out.String())
+	}
+
+	out.Reset()
+	middle = MiddlewareAccessLogger(AccessLogConfig{Format: AccessLogFormatJSON, Output: &out})(handler)
+	req = httptest.NewRequest(http.MethodGet, "/AccessLog", nil)
+	req.SetBasicAuth("gopher", "secret")
+	middle.ServeHTTP(httptest.NewRecorder(), req)
+
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid JSON log line: %s", err)
+	}
+	if entry["status"] != float64(http.StatusCreated) || entry["bytes"] != float64(16) || entry["user"] != "gopher" {
+		t.Errorf("unexpected JSON log entry: %v", entry)
+	}
+}
+
+func TestMiddlewareAccessLoggerSkipAndHandler(t *testing.T) {
+	var out bytes.Buffer
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	middle := MiddlewareAccessLogger(AccessLogConfig{
+		Handler:   slog.NewTextHandler(&out, nil),
+		SkipPaths: []string{"/health"},
+	})(handler)
+
+	middle.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	if out.Len() != 0 {
+		t.Errorf("skipped path was logged: %v", out.String())
+	}
+
+	middle.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/AccessLog", nil))
+	if !strings.Contains(out.String(), "msg=access") || !strings.Contains(out.String(), "status=200") {
+		t.Errorf("unexpected slog output: %v", out.String())
+	}
+}
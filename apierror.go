@@ -0,0 +1,198 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const (
+	ErrorFormatJSON    = "json"
+	ErrorFormatProblem = "problem"
+)
+
+/*
+Output format used by RespondWithError, ErrorFormatJSON or ErrorFormatProblem
+(RFC 7807 application/problem+json). It applies to the whole process and must only be
+set once at init, before serving requests; use ServiceConfig.ErrorFormat to choose the
+format of a single Service
+*/
+var ErrorFormat = ErrorFormatJSON
+
+type errorFormatContextKey struct{}
+
+/*
+Return the error format set on the request context by the Service, ErrorFormat when unset
+*/
+func errorFormatFromContext(ctx context.Context) string {
+	if format, ok := ctx.Value(errorFormatContextKey{}).(string); ok {
+		return format
+	}
+	return ErrorFormat
+}
+
+/*
+Field level detail of an APIError, e.g. a failed validation of a request input
+*/
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Value   string `json:"value,omitempty"` // Raw input value, when it was provided
+}
+
+/*
+Error carrying the HTTP status, a stable machine readable code and a client safe
+message. Cause is kept for logging and errors.Is/As but never sent to the client
+*/
+type APIError struct {
+	Status  int
+	Code    string
+	Message string
+	Details []FieldError
+	Cause   error
+}
+
+/*
+Return a stable error code from an HTTP status, e.g. 404 "not_found"
+*/
+func statusCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "unknown_error"
+	}
+	return strings.ReplaceAll(strings.ToLower(strings.ReplaceAll(text, "-", " ")), " ", "_")
+}
+
+/*
+Create a new APIError, code and message default to the HTTP status text when empty
+*/
+func NewAPIError(status int, code string, message string) *APIError {
+	if code == "" {
+		code = statusCode(status)
+	}
+	if message == "" {
+		message = strings.ToLower(http.StatusText(status))
+	}
+	return &APIError{Status: status, Code: code, Message: message}
+}
+
+func (e *APIError) Error() string {
+	if e.Cause != nil {
+		return e.Message + ": " + e.Cause.Error()
+	}
+	return e.Message
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Cause
+}
+
+/*
+Return a copy of the error with the cause attached
+*/
+func (e *APIError) WithCause(err error) *APIError {
+	c := *e
+	c.Cause = err
+	return &c
+}
+
+/*
+Return a copy of the error with the field details appended
+*/
+func (e *APIError) WithDetails(details ...FieldError) *APIError {
+	c := *e
+	c.Details = append(append([]FieldError{}, e.Details...), details...)
+	return &c
+}
+
+/*
+Find the APIError in the error chain, any other error is mapped to a generic 500
+without exposing its text. APIErrors without a valid status, e.g. literals without
+Status, are returned as a copy with status 500
+*/
+func AsAPIError(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.Status < 100 || apiErr.Status > 999 {
+			c := *apiErr
+			c.Status = http.StatusInternalServerError
+			if c.Code == "" {
+				c.Code = statusCode(c.Status)
+			}
+			if c.Message == "" {
+				c.Message = strings.ToLower(http.StatusText(c.Status))
+			}
+			return &c
+		}
+		return apiErr
+	}
+	return NewAPIError(http.StatusInternalServerError, "", "").WithCause(err)
+}
+
+type errorBody struct {
+	Message   string       `json:"message"`
+	Code      string       `json:"code"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+type problemBody struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+/*
+Write the error in the format selected by ErrorFormat, the HTTP status and body are
+taken from the APIError found in the error chain
+*/
+func RespondWithError(w http.ResponseWriter, err error) {
+	respondWithError(w, err, ErrorFormat)
+}
+
+func respondWithError(w http.ResponseWriter, err error, format string) {
+	if format == ErrorFormatProblem {
+		RespondWithProblem(w, err)
+		return
+	}
+
+	apiErr := AsAPIError(err)
+	RespondWithJSON(w, apiErr.Status, errorBody{
+		Message:   apiErr.Message,
+		Code:      apiErr.Code,
+		Details:   apiErr.Details,
+		RequestID: w.Header().Get(RequestIDHeader),
+	})
+}
+
+/*
+Write the error as an RFC 7807 application/problem+json document
+*/
+func RespondWithProblem(w http.ResponseWriter, err error) {
+	apiErr := AsAPIError(err)
+	instance := ""
+	if id := w.Header().Get(RequestIDHeader); id != "" {
+		instance = "urn:request:" + id
+	}
+
+	response, _ := json.Marshal(problemBody{
+		Type:     "about:blank",
+		Title:    http.StatusText(apiErr.Status),
+		Status:   apiErr.Status,
+		Detail:   apiErr.Message,
+		Instance: instance,
+		Code:     apiErr.Code,
+		Errors:   apiErr.Details,
+	})
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(apiErr.Status)
+	w.Write(response)
+}
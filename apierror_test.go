@@ -0,0 +1,79 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIError(t *testing.T) {
+	cause := errors.New("sql: no rows in result set")
+	err := fmt.Errorf("find user: %w", NewAPIError(http.StatusNotFound, "", "user not found").WithCause(cause))
+
+	if !errors.Is(err, cause) {
+		t.Errorf("expected the APIError cause to be found with errors.Is")
+	}
+
+	apiErr := AsAPIError(err)
+	if apiErr.Status != http.StatusNotFound || apiErr.Code != "not_found" || apiErr.Message != "user not found" {
+		t.Errorf("unexpected APIError: %+v", apiErr)
+	}
+
+	apiErr = AsAPIError(cause)
+	if apiErr.Status != http.StatusInternalServerError || apiErr.Code != "internal_server_error" {
+		t.Errorf("unexpected APIError for a plain error: %+v", apiErr)
+	}
+
+	apiErr = AsAPIError(&APIError{Code: "broken", Message: "something broke"})
+	if apiErr.Status != http.StatusInternalServerError || apiErr.Code != "broken" || apiErr.Message != "something broke" {
+		t.Errorf("unexpected APIError without status: %+v", apiErr)
+	}
+}
+
+func TestRespondWithError(t *testing.T) {
+	err := NewAPIError(http.StatusUnprocessableEntity, "validation_failed", "invalid input").WithDetails(
+		FieldError{Field: "email", Code: "required", Message: "email is required"},
+	)
+
+	rr := httptest.NewRecorder()
+	RespondWithError(rr, err)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusUnprocessableEntity)
+	}
+	expected := `{"message":"invalid input","code":"validation_failed","details":[{"field":"email","code":"required","message":"email is required"}]}`
+	if rr.Body.String() != expected {
+		t.Errorf("handler returned unexpected body: \n\t got %v\n\twant %v", rr.Body.String(), expected)
+	}
+
+	rr = httptest.NewRecorder()
+	RespondWithError(rr, errors.New("dial tcp 10.0.0.1:5432: connection refused"))
+
+	expected = `{"message":"internal server error","code":"internal_server_error"}`
+	if rr.Code != http.StatusInternalServerError || rr.Body.String() != expected {
+		t.Errorf("handler returned unexpected response: \n\t got %v %v\n\twant %v %v", rr.Code, rr.Body.String(), http.StatusInternalServerError, expected)
+	}
+
+	rr = httptest.NewRecorder()
+	RespondWithError(rr, &APIError{})
+
+	if rr.Code != http.StatusInternalServerError || rr.Body.String() != expected {
+		t.Errorf("handler returned unexpected response: \n\t got %v %v\n\twant %v %v", rr.Code, rr.Body.String(), http.StatusInternalServerError, expected)
+	}
+}
+
+func TestRespondWithProblem(t *testing.T) {
+	rr := httptest.NewRecorder()
+	rr.Header().Set(RequestIDHeader, "abc-123")
+	RespondWithProblem(rr, NewAPIError(http.StatusForbidden, "", "access denied"))
+
+	if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("unexpected content type: got %v want application/problem+json", ct)
+	}
+	expected := `{"type":"about:blank","title":"Forbidden","status":403,"detail":"access denied","instance":"urn:request:abc-123","code":"forbidden"}`
+	if rr.Body.String() != expected {
+		t.Errorf("handler returned unexpected body: \n\t got %v\n\twant %v", rr.Body.String(), expected)
+	}
+}
@@ -0,0 +1,177 @@
+package rest
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+/*
+Resolve the client IP address of requests received through reverse proxies. Forwarding
+headers are only read when the peer address belongs to a trusted proxy, and the hops are
+walked right to left skipping the trusted ones, so clients can not spoof their address
+*/
+type ClientIPResolver struct {
+	trusted []netip.Prefix
+}
+
+/*
+Create a resolver trusting the proxies in the CIDR ranges or single addresses, e.g.
+"10.0.0.0/8" or "192.168.1.10". Without trusted proxies the peer address is always used
+*/
+func NewClientIPResolver(trustedProxies ...string) (*ClientIPResolver, error) {
+	prefixes, err := parsePrefixes(trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	return &ClientIPResolver{trusted: prefixes}, nil
+}
+
+/*
+Parse CIDR ranges or single addresses, single addresses match only themselves
+*/
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if strings.Contains(value, "/") {
+			prefix, err := netip.ParsePrefix(value)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, err
+		}
+		addr = addr.WithZone("")
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+/*
+Return true when the address belongs to one of the prefixes. IPv4-mapped IPv6 addresses
+match IPv4 prefixes and zones are ignored
+*/
+func prefixesContain(prefixes []netip.Prefix, addr netip.Addr) bool {
+	addr = addr.WithZone("")
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) || prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Parse an address with an optional port, IPv6 addresses with port must be enclosed in
+brackets, e.g. "[2001:db8::1]:8080"
+*/
+func parseHostAddr(value string) (netip.Addr, bool) {
+	value = strings.TrimSpace(value)
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr, true
+}
+
+/*
+Return the "for" addresses of the RFC 7239 Forwarded headers, from the first to the last
+hop. Obfuscated or unknown identifiers are returned as they are
+*/
+func forwardedFor(r *http.Request) []string {
+	hops := []string{}
+	for _, header := range r.Header.Values("Forwarded") {
+		for _, element := range strings.Split(header, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, value, _ := strings.Cut(strings.TrimSpace(pair), "=")
+				if strings.EqualFold(key, "for") {
+					hops = append(hops, strings.Trim(value, "\""))
+				}
+			}
+		}
+	}
+	return hops
+}
+
+/*
+Return the X-Forwarded-For addresses, from the first to the last hop
+*/
+func xffHops(r *http.Request) []string {
+	hops := []string{}
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
+
+/*
+Return true when the address is a trusted proxy. A nil resolver trusts no proxies
+*/
+func (c *ClientIPResolver) Trusted(addr netip.Addr) bool {
+	if c == nil {
+		return false
+	}
+	return prefixesContain(c.trusted, addr)
+}
+
+/*
+Return the client address of the request. When the peer is a trusted proxy the
+Forwarded header (or X-Forwarded-For when absent) is walked from the last hop and the
+first untrusted address is returned; X-Real-Ip is used when neither is present. An
+invalid hop stops the walk at the last trusted address
+*/
+func (c *ClientIPResolver) ClientAddr(r *http.Request) netip.Addr {
+	addr, ok := parseHostAddr(r.RemoteAddr)
+	if !ok || !c.Trusted(addr) {
+		return addr
+	}
+
+	hops := forwardedFor(r)
+	if len(hops) == 0 {
+		hops = xffHops(r)
+	}
+	if len(hops) == 0 {
+		if xri, ok := parseHostAddr(r.Header.Get("X-Real-Ip")); ok {
+			return xri
+		}
+		return addr
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseHostAddr(hops[i])
+		if !ok {
+			return addr
+		}
+		addr = hop
+		if !c.Trusted(addr) {
+			return addr
+		}
+	}
+	return addr
+}
+
+/*
+Same as ClientAddr returning the address as a string, empty when RemoteAddr is invalid
+*/
+func (c *ClientIPResolver) ClientIP(r *http.Request) string {
+	addr := c.ClientAddr(r)
+	if !addr.IsValid() {
+		return ""
+	}
+	return addr.Unmap().String()
+}
@@ -0,0 +1,53 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPResolver(t *testing.T) {
+	resolver, err := NewClientIPResolver("10.0.0.0/8", "192.168.1.10", "2001:db8::/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		remoteAddr string
+		headers    map[string]string
+		expected   string
+	}{
+		{"203.0.113.5:1234", map[string]string{"X-Forwarded-For": "1.1.1.1", "X-Real-Ip": "2.2.2.2"}, "203.0.113.5"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.1.1.1, 203.0.113.7, 10.0.0.2"}, "203.0.113.7"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.1.1.1, garbage, 10.0.0.2"}, "10.0.0.2"},
+		{"192.168.1.10:80", map[string]string{"X-Real-Ip": "198.51.100.1"}, "198.51.100.1"},
+		{"192.168.1.11:80", map[string]string{"X-Real-Ip": "198.51.100.1"}, "192.168.1.11"},
+		{"10.0.0.1:1234", map[string]string{
+			"Forwarded":       `for=198.51.100.9;proto=https, for="[2001:db8:cafe::17]:4711"`,
+			"X-Forwarded-For": "1.1.1.1",
+		}, "198.51.100.9"},
+		{"10.0.0.1:1234", map[string]string{"Forwarded": `for=198.51.100.9, for="_hidden"`}, "10.0.0.1"},
+		{"[2001:db8::1]:443", map[string]string{"X-Forwarded-For": "[2001:db9::5]:1000"}, "2001:db9::5"},
+		{"[::ffff:10.0.0.1]:443", map[string]string{"X-Forwarded-For": "198.51.100.2"}, "198.51.100.2"},
+		{"invalid", map[string]string{"X-Forwarded-For": "198.51.100.2"}, ""},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = test.remoteAddr
+		for key, value := range test.headers {
+			r.Header.Set(key, value)
+		}
+		if ip := resolver.ClientIP(r); ip != test.expected {
+			t.Errorf("%s %v: unexpected IP value: \n\t got %v\n\twant %v", test.remoteAddr, test.headers, ip, test.expected)
+		}
+	}
+
+	if _, err := NewClientIPResolver("10.0.0.0/33"); err == nil {
+		t.Errorf("invalid CIDR accepted")
+	}
+	if _, err := NewClientIPResolver("proxy.local"); err == nil {
+		t.Errorf("invalid address accepted")
+	}
+}
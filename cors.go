@@ -0,0 +1,288 @@
+package rest
+
+import (
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+/*
+Cross-origin resource sharing settings
+*/
+type CORSConfig struct {
+	AllowedOrigins        []string         // Exact origins, "*" or wildcard subdomains e.g. "https://*.example.com"
+	AllowedOriginPatterns []*regexp.Regexp // Expressions matched against the whole Origin header, they are anchored at both ends
+	AllowedMethods        []string         // Default GET, HEAD and POST
+	AllowedHeaders        []string         // Request headers allowed in preflights, "*" allows any. Default Content-Type, Authorization and X-Request-ID
+	ExposedHeaders        []string         // Response headers readable by the browser
+	AllowCredentials      bool             // Allow cookies and authorization headers, the origin is echoed instead of "*"
+	MaxAge                time.Duration    // Time browsers may cache preflight responses
+}
+
+/*
+CORS policy built from a CORSConfig. Use Handler for single routes or Router to handle
+the preflights of every route of a mux router
+*/
+type CORS struct {
+	cnf       CORSConfig
+	any       bool
+	origins   map[string]bool
+	wildcards [][2]string
+	patterns  []*regexp.Regexp
+	methods   []string
+	headers   map[string]bool
+	anyHeader bool
+	exposed   string
+	maxAge    string
+}
+
+func NewCORS(cnf CORSConfig) *CORS {
+	if len(cnf.AllowedMethods) == 0 {
+		cnf.AllowedMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	}
+	if len(cnf.AllowedHeaders) == 0 {
+		cnf.AllowedHeaders = []string{"Content-Type", "Authorization", RequestIDHeader}
+	}
+
+	c := &CORS{cnf: cnf, origins: map[string]bool{}, headers: map[string]bool{}}
+	for _, pattern := range cnf.AllowedOriginPatterns {
+		c.patterns = append(c.patterns, regexp.MustCompile(`^(?:`+pattern.String()+`)$`))
+	}
+	for _, origin := range cnf.AllowedOrigins {
+		origin = strings.ToLower(origin)
+		if origin == "*" {
+			c.any = true
+		} else if prefix, suffix, ok := strings.Cut(origin, "*"); ok {
+			c.wildcards = append(c.wildcards, [2]string{prefix, suffix})
+		} else {
+			c.origins[origin] = true
+		}
+	}
+	for _, method := range cnf.AllowedMethods {
+		c.methods = append(c.methods, strings.ToUpper(method))
+	}
+	for _, header := range cnf.AllowedHeaders {
+		if header == "*" {
+			c.anyHeader = true
+		}
+		c.headers[http.CanonicalHeaderKey(header)] = true
+	}
+	c.exposed = strings.Join(cnf.ExposedHeaders, ", ")
+	if cnf.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(cnf.MaxAge.Seconds()))
+	}
+
+	return c
+}
+
+/*
+Return true when the origin is allowed by the exact, wildcard or regex origins
+*/
+func (c *CORS) allowedOrigin(origin string) bool {
+	if c.any {
+		return true
+	}
+	lower := strings.ToLower(origin)
+	if c.origins[lower] {
+		return true
+	}
+	for _, wildcard := range c.wildcards {
+		if len(lower) > len(wildcard[0])+len(wildcard[1]) && strings.HasPrefix(lower, wildcard[0]) && strings.HasSuffix(lower, wildcard[1]) &&
+			!strings.Contains(lower[len(wildcard[0]):len(lower)-len(wildcard[1])], "/") {
+			return true
+		}
+	}
+	for _, pattern := range c.patterns {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Return true when every header of an Access-Control-Request-Headers value is allowed
+*/
+func (c *CORS) allowedHeaders(requested string) bool {
+	if c.anyHeader {
+		return true
+	}
+	for _, header := range strings.Split(requested, ",") {
+		if header = strings.TrimSpace(header); header != "" && !c.headers[http.CanonicalHeaderKey(header)] {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+Remove the CORS headers set by an outer policy, so route policies replace the service one
+*/
+func resetCORSHeaders(header http.Header) {
+	for key := range header {
+		if strings.HasPrefix(key, "Access-Control-") {
+			header.Del(key)
+		}
+	}
+}
+
+/*
+Add a value to the Vary header unless it is already present
+*/
+func addVary(header http.Header, value string) {
+	for _, vary := range header.Values("Vary") {
+		if strings.EqualFold(vary, value) {
+			return
+		}
+	}
+	header.Add("Vary", value)
+}
+
+func (c *CORS) setOrigin(header http.Header, origin string) {
+	if c.any && !c.cnf.AllowCredentials {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.cnf.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+/*
+Add the CORS headers of an actual (not preflight) request from an allowed origin
+*/
+func (c *CORS) actual(w http.ResponseWriter, r *http.Request) {
+	header := w.Header()
+	resetCORSHeaders(header)
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+	if !c.any || c.cnf.AllowCredentials {
+		addVary(header, "Origin")
+	}
+	if !c.allowedOrigin(origin) {
+		return
+	}
+	c.setOrigin(header, origin)
+	if c.exposed != "" {
+		header.Set("Access-Control-Expose-Headers", c.exposed)
+	}
+}
+
+/*
+Answer a preflight request with status 204. The CORS headers are only sent when the
+origin, requested method (one of methods) and requested headers are allowed, otherwise
+the browser blocks the actual request
+*/
+func (c *CORS) preflight(w http.ResponseWriter, r *http.Request, methods []string) {
+	header := w.Header()
+	resetCORSHeaders(header)
+	addVary(header, "Origin")
+	addVary(header, "Access-Control-Request-Method")
+	addVary(header, "Access-Control-Request-Headers")
+
+	origin := r.Header.Get("Origin")
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	requested := strings.Join(r.Header.Values("Access-Control-Request-Headers"), ",")
+
+	if slices.Contains(methods, method) && c.allowedOrigin(origin) && c.allowedHeaders(requested) {
+		c.setOrigin(header, origin)
+		header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		if strings.TrimSpace(requested) != "" {
+			header.Set("Access-Control-Allow-Headers", requested)
+		}
+		if c.maxAge != "" {
+			header.Set("Access-Control-Max-Age", c.maxAge)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Origin") != "" && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+/*
+Handler applying a CORS policy to a single route
+*/
+type corsHandler struct {
+	cors *CORS
+	next http.Handler
+}
+
+func (h *corsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if isPreflight(r) {
+		h.cors.preflight(w, r, h.cors.methods)
+		return
+	}
+	h.cors.actual(w, r)
+	h.next.ServeHTTP(w, r)
+}
+
+/*
+Apply the policy to a handler. Preflights are answered with the configured methods, so
+the route must also match OPTIONS, e.g. route.Methods("GET", "OPTIONS"). Inside a
+Router wrapped policy the route policy replaces the router one
+*/
+func (c *CORS) Handler(next http.Handler) http.Handler {
+	return &corsHandler{cors: c, next: next}
+}
+
+/*
+Return the route matching the request, nil when no route matches its path and method
+*/
+func matchRoute(router *mux.Router, r *http.Request) *mux.Route {
+	match := mux.RouteMatch{}
+	if !router.Match(r, &match) || match.MatchErr != nil {
+		return nil
+	}
+	return match.Route
+}
+
+/*
+Apply the policy to every route of the router. Preflights are answered before routing
+with the allowed methods the routes of the path accept, and routes with their own policy
+(see Handler) are answered with it
+*/
+func (c *CORS) Router(router *mux.Router) http.Handler {
+	return http.HandlerFunc(
+		func(response http.ResponseWriter, request *http.Request) {
+			if !isPreflight(request) {
+				c.actual(response, request)
+				router.ServeHTTP(response, request)
+				return
+			}
+
+			policy := c
+			probe := request.Clone(request.Context())
+			probe.Method = strings.ToUpper(request.Header.Get("Access-Control-Request-Method"))
+			if route := matchRoute(router, probe); route != nil {
+				if h, ok := route.GetHandler().(*corsHandler); ok {
+					policy = h.cors
+				}
+			}
+
+			methods := []string{}
+			for _, method := range policy.methods {
+				probe.Method = method
+				if matchRoute(router, probe) != nil {
+					methods = append(methods, method)
+				}
+			}
+			if len(methods) == 0 {
+				router.ServeHTTP(response, request)
+				return
+			}
+
+			policy.preflight(response, request, methods)
+		})
+}
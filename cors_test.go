@@ -0,0 +1,140 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestCORSOrigins(t *testing.T) {
+	cors := NewCORS(CORSConfig{
+		AllowedOrigins:        []string{"https://app.example.com", "https://*.example.org"},
+		AllowedOriginPatterns: []*regexp.Regexp{regexp.MustCompile(`^http://localhost:\d+$`), regexp.MustCompile(`https://[a-z]+\.example\.net`)},
+	})
+
+	tests := []struct {
+		origin   string
+		expected bool
+	}{
+		{"https://app.example.com", true},
+		{"https://APP.example.com", true},
+		{"http://app.example.com", false},
+		{"https://api.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"https://.example.org", false},
+		{"https://evil.com/.example.org", false},
+		{"https://evilexample.org", false},
+		{"http://localhost:3000", true},
+		{"http://localhost:3000.evil.com", false},
+		{"https://a.example.net", true},
+		{"https://a.example.net.evil.com", false},
+		{"https://evil.com/https://a.example.net", false},
+	}
+	for _, test := range tests {
+		if allowed := cors.allowedOrigin(test.origin); allowed != test.expected {
+			t.Errorf("%s: unexpected result: got %v want %v", test.origin, allowed, test.expected)
+		}
+	}
+}
+
+func newPreflightRequest(path string, origin string, method string, headers string) *http.Request {
+	r := httptest.NewRequest(http.MethodOptions, path, nil)
+	r.Header.Set("Origin", origin)
+	r.Header.Set("Access-Control-Request-Method", method)
+	if headers != "" {
+		r.Header.Set("Access-Control-Request-Headers", headers)
+	}
+	return r
+}
+
+func TestCORSRouter(t *testing.T) {
+	router := mux.NewRouter()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		RespondWithJSONMessage(w, http.StatusOK, "OK")
+	})
+	router.Handle("/users", ok).Methods(http.MethodGet, http.MethodPost)
+	router.Handle("/users/{id}", ok).Methods(http.MethodDelete)
+
+	public := NewCORS(CORSConfig{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET", "OPTIONS"}})
+	router.Handle("/public", public.Handler(ok)).Methods(http.MethodGet, http.MethodOptions)
+
+	handler := NewCORS(CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{"GET", "POST", "DELETE"},
+		ExposedHeaders:   []string{RequestIDHeader},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}).Router(router)
+
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve(newPreflightRequest("/users", "https://app.example.com", "POST", "content-type, authorization"))
+	if w.Code != http.StatusNoContent ||
+		w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		w.Header().Get("Access-Control-Allow-Methods") != "GET, POST" ||
+		w.Header().Get("Access-Control-Allow-Headers") != "content-type, authorization" ||
+		w.Header().Get("Access-Control-Allow-Credentials") != "true" ||
+		w.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("unexpected preflight response: %d %v", w.Code, w.Header())
+	}
+
+	w = serve(newPreflightRequest("/users/1", "https://app.example.com", "DELETE", ""))
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Methods") != "DELETE" {
+		t.Errorf("unexpected preflight response: %d %v", w.Code, w.Header())
+	}
+
+	rejected := []*http.Request{
+		newPreflightRequest("/users", "https://evil.com", "POST", ""),
+		newPreflightRequest("/users", "https://app.example.com", "DELETE", ""),
+		newPreflightRequest("/users", "https://app.example.com", "POST", "X-Custom"),
+	}
+	for _, r := range rejected {
+		if w = serve(r); w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("unexpected rejected preflight response: %d %v", w.Code, w.Header())
+		}
+	}
+
+	if w = serve(newPreflightRequest("/missing", "https://app.example.com", "GET", "")); w.Code != http.StatusNotFound {
+		t.Errorf("unexpected preflight status for an unknown path: %d", w.Code)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/users", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	w = serve(r)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		w.Header().Get("Access-Control-Expose-Headers") != RequestIDHeader || w.Header().Get("Vary") != "Origin" {
+		t.Errorf("unexpected response: %d %v", w.Code, w.Header())
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/public", nil)
+	r.Header.Set("Origin", "https://other.com")
+	w = serve(r)
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" || w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("route policy not applied: %v", w.Header())
+	}
+
+	w = serve(newPreflightRequest("/public", "https://other.com", "GET", ""))
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "*" || w.Header().Get("Access-Control-Allow-Methods") != "GET, OPTIONS" {
+		t.Errorf("unexpected route preflight response: %d %v", w.Code, w.Header())
+	}
+}
+
+func TestServiceCORS(t *testing.T) {
+	srv := NewService(ServiceConfig{CORS: &CORSConfig{AllowedOrigins: []string{"*"}}})
+	srv.Router().HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodGet)
+
+	w := httptest.NewRecorder()
+	srv.srv.Handler.ServeHTTP(w, newPreflightRequest("/", "https://app.example.com", "GET", ""))
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("unexpected preflight response: %d %v", w.Code, w.Header())
+	}
+}
@@ -0,0 +1,113 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const defaultMaxBodySize = 1 << 20
+
+/*
+Settings of DecodeJSON, the zero value limits bodies to 1MB and allows unknown fields
+*/
+type DecodeJSONConfig struct {
+	MaxBodySize           int64 // Maximum body size in bytes, default 1MB
+	DisallowUnknownFields bool  // Reject bodies with fields not present in the model
+}
+
+/*
+Return true if the request Content-Type is application/json or a +json media type
+*/
+func isJSONContentType(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+/*
+Decode the JSON body of the request into the model and validate it with its "validate"
+struct tags. Failures are returned as APIError values ready for RespondWithError
+*/
+func DecodeJSON(r *http.Request, model interface{}, cnf DecodeJSONConfig) error {
+	if err := decodeJSON(r, model, cnf); err != nil {
+		return err
+	}
+	return Validate(model)
+}
+
+/*
+Decode the JSON body into the model without validating it
+*/
+func decodeJSON(r *http.Request, model interface{}, cnf DecodeJSONConfig) error {
+	if cnf.MaxBodySize <= 0 {
+		cnf.MaxBodySize = defaultMaxBodySize
+	}
+
+	if !isJSONContentType(r) {
+		return NewAPIError(http.StatusUnsupportedMediaType, "", "content type must be application/json")
+	}
+	if r.Body == nil {
+		return NewAPIError(http.StatusBadRequest, "invalid_body", "request body is empty")
+	}
+
+	body := io.LimitReader(r.Body, cnf.MaxBodySize+1)
+	counter := &countingReader{r: body}
+	decoder := json.NewDecoder(counter)
+	if cnf.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+
+	err := decoder.Decode(model)
+	if counter.n > cnf.MaxBodySize {
+		return NewAPIError(http.StatusRequestEntityTooLarge, "", "request body is too large")
+	}
+	if errors.Is(err, io.EOF) {
+		return NewAPIError(http.StatusBadRequest, "invalid_body", "request body is empty")
+	}
+	if err != nil {
+		return NewAPIError(http.StatusBadRequest, "invalid_body", jsonErrorMessage(err)).WithCause(err)
+	}
+	if decoder.More() {
+		return NewAPIError(http.StatusBadRequest, "invalid_body", "request body must contain a single JSON value")
+	}
+
+	return nil
+}
+
+/*
+Return a client safe description of a JSON decoding error
+*/
+func jsonErrorMessage(err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return "request body contains malformed JSON"
+	case errors.As(err, &typeErr):
+		if typeErr.Field != "" {
+			return "invalid value for field \"" + typeErr.Field + "\""
+		}
+		return "invalid value type in request body"
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return "request body contains unknown field " + strings.TrimPrefix(err.Error(), "json: unknown field ")
+	}
+	return "invalid request body"
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,54 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	type Test struct {
+		Name  string `json:"name" validate:"required"`
+		Email string `json:"email" validate:"email"`
+	}
+
+	newRequest := func(contentType string, body string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/DecodeJSON", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		return r
+	}
+
+	test := Test{}
+	if err := DecodeJSON(newRequest("application/json; charset=utf-8", `{"name":"Gopher"}`), &test, DecodeJSONConfig{}); err != nil {
+		t.Errorf("DecodeJSON fail! error: %s", err)
+	} else if test.Name != "Gopher" {
+		t.Errorf("DecodeJSON Unexpected value for field \"Name\":\nGot  %v\nWant Gopher", test.Name)
+	}
+
+	tests := []struct {
+		request *http.Request
+		cnf     DecodeJSONConfig
+		status  int
+	}{
+		{newRequest("text/plain", `{"name":"Gopher"}`), DecodeJSONConfig{}, http.StatusUnsupportedMediaType},
+		{newRequest("application/json", `{"name":`), DecodeJSONConfig{}, http.StatusBadRequest},
+		{newRequest("application/json", ``), DecodeJSONConfig{}, http.StatusBadRequest},
+		{newRequest("application/json", `{"name":"Gopher"}{}`), DecodeJSONConfig{}, http.StatusBadRequest},
+		{newRequest("application/json", `{"name":"Gopher","age":1}`), DecodeJSONConfig{DisallowUnknownFields: true}, http.StatusBadRequest},
+		{newRequest("application/json", `{"name":"`+strings.Repeat("a", 64)+`"}`), DecodeJSONConfig{MaxBodySize: 32}, http.StatusRequestEntityTooLarge},
+		{newRequest("application/json", `{"email":"invalid"}`), DecodeJSONConfig{}, http.StatusUnprocessableEntity},
+	}
+
+	for i, test := range tests {
+		err := DecodeJSON(test.request, &Test{}, test.cnf)
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			t.Errorf("case %d: expected an APIError, got %v", i, err)
+		} else if apiErr.Status != test.status {
+			t.Errorf("case %d: unexpected status: got %v want %v", i, apiErr.Status, test.status)
+		}
+	}
+}
@@ -1,6 +1,51 @@
 package rest
 
-import "errors"
+import (
+	"errors"
+	"net/http"
+)
 
 var ErrFormToStructPtrExpected = errors.New("expected error FormToStruct function")
 var ErrGracefullShutdown = errors.New("service stopped gracefully")
+
+var ErrJWTMissing = errors.New("authorization token required")
+var ErrJWTMalformed = errors.New("malformed authorization token")
+var ErrJWTAlgorithm = errors.New("unexpected authorization token signing algorithm")
+var ErrJWTKey = errors.New("invalid authorization token signing key")
+var ErrJWTSignature = errors.New("invalid authorization token signature")
+var ErrJWTExpired = errors.New("authorization token is expired")
+var ErrJWTNotValidYet = errors.New("authorization token is not valid yet")
+var ErrJWTIssuedAt = errors.New("authorization token used before issued")
+var ErrJWTIssuer = errors.New("invalid authorization token issuer")
+var ErrJWTAudience = errors.New("invalid authorization token audience")
+
+var ErrTLSCertificateRequired = errors.New("TLS certificate and key files are required")
+var ErrTLSClientCA = errors.New("no valid certificates found in TLS client CA file")
+
+var ErrInternalServer = errors.New("internal server error")
+
+var ErrUploadMissingFile = NewAPIError(http.StatusBadRequest, "missing_file", "no file uploaded")
+var ErrUploadTooLarge = NewAPIError(http.StatusRequestEntityTooLarge, "file_too_large", "uploaded file is too large")
+var ErrUploadExtension = NewAPIError(http.StatusUnsupportedMediaType, "file_extension_not_allowed", "uploaded file extension is not allowed")
+var ErrUploadMIMEType = NewAPIError(http.StatusUnsupportedMediaType, "file_type_not_allowed", "uploaded file type is not allowed")
+var ErrUploadTruncated = errors.New("uploaded file is truncated")
+var ErrUploadTooManyFiles = NewAPIError(http.StatusRequestEntityTooLarge, "too_many_files", "too many uploaded files")
+var ErrUploadFieldTooLarge = NewAPIError(http.StatusRequestEntityTooLarge, "field_too_large", "form field is too large")
+var ErrUploadFieldsTooLarge = NewAPIError(http.StatusRequestEntityTooLarge, "fields_too_large", "form fields are too large")
+var ErrUploadTooManyParts = NewAPIError(http.StatusRequestEntityTooLarge, "too_many_parts", "too many form parts")
+
+var ErrStorageNotFound = NewAPIError(http.StatusNotFound, "file_not_found", "file not found")
+var ErrStorageInvalidKey = NewAPIError(http.StatusBadRequest, "invalid_file_name", "invalid file name")
+
+var ErrResumableVersion = NewAPIError(http.StatusPreconditionFailed, "unsupported_tus_version", "unsupported tus protocol version")
+var ErrResumableNotFound = NewAPIError(http.StatusNotFound, "upload_not_found", "upload not found")
+var ErrResumableExpired = NewAPIError(http.StatusGone, "upload_expired", "upload is expired")
+var ErrResumableLength = NewAPIError(http.StatusBadRequest, "invalid_upload_length", "invalid upload length")
+var ErrResumableMetadata = NewAPIError(http.StatusBadRequest, "invalid_upload_metadata", "invalid upload metadata")
+var ErrResumableOffset = NewAPIError(http.StatusConflict, "upload_offset_mismatch", "upload offset does not match")
+var ErrResumableContentType = NewAPIError(http.StatusUnsupportedMediaType, "invalid_content_type", "content type must be application/offset+octet-stream")
+var ErrResumableLocked = NewAPIError(http.StatusLocked, "upload_locked", "upload is being written by another request")
+
+var ErrRateLimited = errors.New("too many requests")
+var ErrRateLimitInvalid = errors.New("rate limit must be greater than zero")
+var ErrRateLimitAlgorithm = errors.New("unknown rate limit algorithm")
@@ -1,5 +1,8 @@
 module github.com/artziel/go-api-service
 
-go 1.19
+go 1.21
 
-require github.com/gorilla/mux v1.8.0
+require (
+	github.com/gorilla/mux v1.8.0
+	golang.org/x/text v0.14.0
+)
@@ -0,0 +1,110 @@
+package rest
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"reflect"
+
+	"github.com/gorilla/mux"
+)
+
+/*
+Handler that returns an error instead of writing it. Errors are rendered with
+RespondWithError, in the format of the Service routing the request, when the handler did
+not write a response yet. Errors rendered with a 5xx status are logged with the request
+ID and route as they may hide their cause
+*/
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+func (h HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sw := newStatusWriter(w)
+	err := h(sw, r)
+	if err == nil {
+		return
+	}
+	if sw.Written() {
+		log.Printf("handler error after response was written - Request ID: %s - Route: %s - %s\n",
+			RequestIDFromContext(r.Context()), routeName(r), err)
+		return
+	}
+	if AsAPIError(err).Status >= http.StatusInternalServerError {
+		log.Printf("handler error - Request ID: %s - Route: %s - %s\n",
+			RequestIDFromContext(r.Context()), routeName(r), err)
+	}
+	respondWithError(sw, err, errorFormatFromContext(r.Context()))
+}
+
+/*
+Handler that receives a decoded request value and returns the response value
+*/
+type TypedHandlerFunc[Req any, Resp any] func(ctx context.Context, req Req) (Resp, error)
+
+/*
+Set the fields with form, query, path or header tags back to their zero value, so only
+BindRequest can fill them
+*/
+func resetBoundFields(v reflect.Value) {
+	for _, tag := range []string{"form", "query", "path", "header"} {
+		for _, field := range formPlan(v.Type(), tag) {
+			value := v.Field(field.index)
+			if !field.embedded {
+				if value.CanSet() {
+					value.Set(reflect.Zero(value.Type()))
+				}
+				continue
+			}
+			if value.Kind() == reflect.Ptr {
+				if value.IsNil() {
+					continue
+				}
+				value = value.Elem()
+			}
+			resetBoundFields(value)
+		}
+	}
+}
+
+/*
+Adapt a typed handler to a HandlerFunc. A JSON request body is decoded into Req, then
+fields with form, query, path or header tags are reset and bound with BindRequest, so
+they can not be set from the body even when the request lacks them. Req is validated
+and the returned Resp is written as JSON with status 200
+*/
+func Typed[Req any, Resp any](h TypedHandlerFunc[Req, Resp]) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var req Req
+		isStruct := reflect.TypeOf(req) != nil && reflect.TypeOf(req).Kind() == reflect.Struct
+		hasBody := r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+
+		if hasBody && (isJSONContentType(r) || !isStruct) {
+			if err := decodeJSON(r, &req, DecodeJSONConfig{}); err != nil {
+				return err
+			}
+		}
+		if isStruct {
+			resetBoundFields(reflect.ValueOf(&req).Elem())
+			if err := BindRequest(r, &req); err != nil {
+				return err
+			}
+		}
+		if err := Validate(&req); err != nil {
+			return err
+		}
+
+		resp, err := h(r.Context(), req)
+		if err != nil {
+			return err
+		}
+
+		RespondWithJSON(w, http.StatusOK, resp)
+		return nil
+	}
+}
+
+/*
+Register a HandlerFunc on the service router
+*/
+func (s *Service) Handle(path string, h HandlerFunc) *mux.Route {
+	return s.router.Handle(path, h)
+}
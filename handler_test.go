@@ -0,0 +1,229 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandlerFunc(t *testing.T) {
+	srv := NewService(ServiceConfig{})
+	srv.Handle("/items/{id}", func(w http.ResponseWriter, r *http.Request) error {
+		return NewAPIError(http.StatusNotFound, "", "item not found")
+	}).Methods(http.MethodGet)
+
+	rr := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/items/1", nil))
+
+	expected := `{"message":"item not found","code":"not_found"}`
+	if rr.Code != http.StatusNotFound || rr.Body.String() != expected {
+		t.Errorf("handler returned unexpected response: \n\t got %v %v\n\twant %v %v", rr.Code, rr.Body.String(), http.StatusNotFound, expected)
+	}
+}
+
+func TestHandlerFuncErrorFormat(t *testing.T) {
+	notFound := func(w http.ResponseWriter, r *http.Request) error {
+		return NewAPIError(http.StatusNotFound, "", "item not found")
+	}
+
+	problem := NewService(ServiceConfig{ErrorFormat: ErrorFormatProblem})
+	problem.Handle("/items/{id}", notFound)
+	plain := NewService(ServiceConfig{})
+	plain.Handle("/items/{id}", notFound)
+
+	rr := httptest.NewRecorder()
+	problem.Router().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/items/1", nil))
+	if ct := rr.Header().Get("Content-Type"); rr.Code != http.StatusNotFound || ct != "application/problem+json" {
+		t.Errorf("unexpected problem response: %v %v", rr.Code, ct)
+	}
+
+	rr = httptest.NewRecorder()
+	plain.Router().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/items/1", nil))
+	if ct := rr.Header().Get("Content-Type"); rr.Code != http.StatusNotFound || ct != "application/json" {
+		t.Errorf("unexpected JSON response: %v %v", rr.Code, ct)
+	}
+}
+
+func TestHandlerFuncLogsServerErrors(t *testing.T) {
+	var str bytes.Buffer
+	log.SetOutput(&str)
+
+	srv := NewService(ServiceConfig{})
+	srv.Handle("/files/{id}", func(w http.ResponseWriter, r *http.Request) error {
+		if r.Method == http.MethodDelete {
+			return NewAPIError(http.StatusNotFound, "", "file not found")
+		}
+		return errors.New("write /data/files/1: no space left on device")
+	}).Methods(http.MethodPut, http.MethodDelete)
+
+	rr := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/files/1", nil))
+
+	if rr.Code != http.StatusInternalServerError || strings.Contains(rr.Body.String(), "no space") {
+		t.Errorf("handler returned unexpected response: %v %v", rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(str.String(), "Route: /files/{id} - write /data/files/1: no space left on device") {
+		t.Errorf("server error was not logged: %v", str.String())
+	}
+
+	str.Reset()
+	srv.Router().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/files/1", nil))
+	if str.Len() != 0 {
+		t.Errorf("client error was logged: %v", str.String())
+	}
+}
+
+func TestTyped(t *testing.T) {
+	type greetRequest struct {
+		Name string `json:"name"`
+	}
+	type greetResponse struct {
+		Message string `json:"message"`
+	}
+
+	srv := NewService(ServiceConfig{})
+	srv.Handle("/greet", Typed(func(ctx context.Context, req greetRequest) (greetResponse, error) {
+		if req.Name == "" {
+			return greetResponse{}, NewAPIError(http.StatusBadRequest, "name_required", "name is required")
+		}
+		return greetResponse{Message: "Hello " + req.Name}, nil
+	})).Methods(http.MethodPost)
+
+	newRequest := func(body string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/greet", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		return r
+	}
+
+	rr := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rr, newRequest(`{"name":"Gopher"}`))
+
+	expected := `{"message":"Hello Gopher"}`
+	if rr.Code != http.StatusOK || rr.Body.String() != expected {
+		t.Errorf("handler returned unexpected response: \n\t got %v %v\n\twant %v %v", rr.Code, rr.Body.String(), http.StatusOK, expected)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.Router().ServeHTTP(rr, newRequest(`{}`))
+
+	expected = `{"message":"name is required","code":"name_required"}`
+	if rr.Code != http.StatusBadRequest || rr.Body.String() != expected {
+		t.Errorf("handler returned unexpected response: \n\t got %v %v\n\twant %v %v", rr.Code, rr.Body.String(), http.StatusBadRequest, expected)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.Router().ServeHTTP(rr, newRequest(`{"name":`))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestTypedBindRequest(t *testing.T) {
+	type itemRequest struct {
+		ID      int    `path:"id"`
+		Fields  string `query:"fields,default=all"`
+		Tenant  string `header:"X-Tenant" validate:"required"`
+		Comment string `json:"comment"`
+	}
+
+	srv := NewService(ServiceConfig{})
+	srv.Handle("/items/{id}", Typed(func(ctx context.Context, req itemRequest) (itemRequest, error) {
+		return req, nil
+	})).Methods(http.MethodPut)
+
+	r := httptest.NewRequest(http.MethodPut, "/items/10", strings.NewReader(`{"comment":"hi"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("x-tenant", "acme")
+	rr := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rr, r)
+
+	expected := `{"ID":10,"Fields":"all","Tenant":"acme","comment":"hi"}`
+	if rr.Code != http.StatusOK || rr.Body.String() != expected {
+		t.Errorf("handler returned unexpected response: \n\t got %v %v\n\twant %v %v", rr.Code, rr.Body.String(), http.StatusOK, expected)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.Router().ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/items/abc", nil))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestTypedBoundFieldsOverride(t *testing.T) {
+	type itemRequest struct {
+		ID      int    `path:"id"`
+		Tenant  string `header:"X-Tenant"`
+		Comment string `json:"comment"`
+	}
+
+	srv := NewService(ServiceConfig{})
+	srv.Handle("/items/{id}", Typed(func(ctx context.Context, req itemRequest) (itemRequest, error) {
+		return req, nil
+	})).Methods(http.MethodPut)
+
+	r := httptest.NewRequest(http.MethodPut, "/items/10", strings.NewReader(`{"ID":99,"Tenant":"evil","comment":"hi"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-Tenant", "acme")
+	rr := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rr, r)
+
+	expected := `{"ID":10,"Tenant":"acme","comment":"hi"}`
+	if rr.Code != http.StatusOK || rr.Body.String() != expected {
+		t.Errorf("handler returned unexpected response: \n\t got %v %v\n\twant %v %v", rr.Code, rr.Body.String(), http.StatusOK, expected)
+	}
+}
+
+func TestTypedBoundFieldsAbsent(t *testing.T) {
+	type itemRequest struct {
+		ID      int    `path:"id"`
+		Tenant  string `header:"X-Tenant"`
+		Role    string `query:"role"`
+		Comment string `json:"comment"`
+	}
+
+	srv := NewService(ServiceConfig{})
+	srv.Handle("/items/{id}", Typed(func(ctx context.Context, req itemRequest) (itemRequest, error) {
+		return req, nil
+	})).Methods(http.MethodPut)
+
+	r := httptest.NewRequest(http.MethodPut, "/items/10", strings.NewReader(`{"Tenant":"evil","Role":"admin","comment":"hi"}`))
+	r.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rr, r)
+
+	expected := `{"ID":10,"Tenant":"","Role":"","comment":"hi"}`
+	if rr.Code != http.StatusOK || rr.Body.String() != expected {
+		t.Errorf("handler returned unexpected response: \n\t got %v %v\n\twant %v %v", rr.Code, rr.Body.String(), http.StatusOK, expected)
+	}
+}
+
+func TestTypedForm(t *testing.T) {
+	type greetForm struct {
+		Name string `form:"name" validate:"required"`
+	}
+	type greetResponse struct {
+		Message string `json:"message"`
+	}
+
+	srv := NewService(ServiceConfig{})
+	srv.Handle("/greet", Typed(func(ctx context.Context, req greetForm) (greetResponse, error) {
+		return greetResponse{Message: "Hello " + req.Name}, nil
+	})).Methods(http.MethodPost)
+
+	r := httptest.NewRequest(http.MethodPost, "/greet", strings.NewReader("name=Gopher"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rr, r)
+
+	expected := `{"message":"Hello Gopher"}`
+	if rr.Code != http.StatusOK || rr.Body.String() != expected {
+		t.Errorf("handler returned unexpected response: \n\t got %v %v\n\twant %v %v", rr.Code, rr.Body.String(), http.StatusOK, expected)
+	}
+}
@@ -0,0 +1,84 @@
+package rest
+
+import (
+	"net/http"
+	"net/netip"
+	"sync/atomic"
+)
+
+/*
+Allowed and denied client addresses of an IPFilter
+*/
+type IPFilterConfig struct {
+	Allow    []string          // CIDR ranges or addresses allowed, when empty every address not denied is allowed
+	Deny     []string          // CIDR ranges or addresses denied, checked before Allow
+	ClientIP *ClientIPResolver // Resolver of the client address, default the peer address
+}
+
+type ipFilterRules struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+/*
+Filter of requests by client address. The rules can be replaced at runtime with Reload
+while requests are served
+*/
+type IPFilter struct {
+	resolver *ClientIPResolver
+	rules    atomic.Pointer[ipFilterRules]
+}
+
+func NewIPFilter(cnf IPFilterConfig) (*IPFilter, error) {
+	filter := &IPFilter{resolver: cnf.ClientIP}
+	if err := filter.Reload(cnf.Allow, cnf.Deny); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+/*
+Replace the allowed and denied CIDR ranges or addresses. On error the current rules are kept
+*/
+func (f *IPFilter) Reload(allow []string, deny []string) error {
+	allowed, err := parsePrefixes(allow)
+	if err != nil {
+		return err
+	}
+	denied, err := parsePrefixes(deny)
+	if err != nil {
+		return err
+	}
+	f.rules.Store(&ipFilterRules{allow: allowed, deny: denied})
+	return nil
+}
+
+/*
+Return true when the address is not denied and, if there are allowed ranges, is allowed.
+Invalid addresses are never allowed
+*/
+func (f *IPFilter) Allowed(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	rules := f.rules.Load()
+	if prefixesContain(rules.deny, addr) {
+		return false
+	}
+	return len(rules.allow) == 0 || prefixesContain(rules.allow, addr)
+}
+
+/*
+Middleware that responds with 403 and the restricted endpoint JSON message to requests
+from addresses not allowed by the filter
+*/
+func (f *IPFilter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(response http.ResponseWriter, request *http.Request) {
+			if !f.Allowed(f.resolver.ClientAddr(request)) {
+				RespondWithJSONMessage(response, http.StatusForbidden, restrictedMessage)
+			} else {
+				next.ServeHTTP(response, request)
+			}
+		})
+}
@@ -0,0 +1,77 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIPFilter(t *testing.T) {
+	resolver, err := NewClientIPResolver("10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter, err := NewIPFilter(IPFilterConfig{
+		Allow:    []string{"192.168.0.0/16", "2001:db8::/32"},
+		Deny:     []string{"192.168.1.0/24"},
+		ClientIP: resolver,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := filter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		RespondWithJSONMessage(w, http.StatusOK, "OK")
+	}))
+
+	serve := func(remoteAddr string, xff string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		if xff != "" {
+			req.Header.Set("X-Forwarded-For", xff)
+		}
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res
+	}
+
+	tests := []struct {
+		remoteAddr string
+		xff        string
+		expected   int
+	}{
+		{"192.168.0.5:1234", "", http.StatusOK},
+		{"[::ffff:192.168.0.5]:1234", "", http.StatusOK},
+		{"[2001:db8::5]:1234", "", http.StatusOK},
+		{"192.168.1.5:1234", "", http.StatusForbidden},
+		{"172.16.0.1:1234", "", http.StatusForbidden},
+		{"10.0.0.1:1234", "192.168.0.5", http.StatusOK},
+		{"10.0.0.1:1234", "192.168.1.5", http.StatusForbidden},
+		{"172.16.0.1:1234", "192.168.0.5", http.StatusForbidden},
+		{"invalid", "", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		if res := serve(test.remoteAddr, test.xff); res.Code != test.expected {
+			t.Errorf("%s %s: handler returned wrong status code: got %v want %v", test.remoteAddr, test.xff, res.Code, test.expected)
+		}
+	}
+
+	res := serve("172.16.0.1:1234", "")
+	expected := `{"message":"the request endpoint is restricted"}`
+	if res.Body.String() != expected {
+		t.Errorf("handler returned unexpected body: \n\t got %v\n\twant %v", res.Body.String(), expected)
+	}
+
+	if err := filter.Reload([]string{"172.16.0.0/12"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if res := serve("172.16.0.1:1234", ""); res.Code != http.StatusOK {
+		t.Errorf("reloaded rules not applied: got %v want %v", res.Code, http.StatusOK)
+	}
+	if err := filter.Reload([]string{"not an address"}, nil); err == nil {
+		t.Errorf("invalid rule accepted")
+	}
+	if res := serve("172.16.0.1:1234", ""); res.Code != http.StatusOK {
+		t.Errorf("rules changed by a failed reload: got %v want %v", res.Code, http.StatusOK)
+	}
+}
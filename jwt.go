@@ -0,0 +1,351 @@
+package rest
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"hash"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	JWTAlgorithmHS256 = "HS256"
+	JWTAlgorithmHS512 = "HS512"
+	JWTAlgorithmRS256 = "RS256"
+	JWTAlgorithmES256 = "ES256"
+)
+
+type jwtContextKey struct{}
+
+/*
+Claims set of a JSON Web Token. Registered claims (iss, sub, aud, exp, nbf, iat, jti)
+are stored along with any custom claim
+*/
+type JWTClaims map[string]interface{}
+
+/*
+Configuration used to sign and verify JSON Web Tokens.
+
+HS256 and HS512 use SecretKey in the same way NewHash does; RS256 expects
+an *rsa.PrivateKey / *rsa.PublicKey pair and ES256 an *ecdsa.PrivateKey /
+*ecdsa.PublicKey pair on the P-256 curve
+*/
+type JWTConfig struct {
+	Algorithm  string
+	SecretKey  string
+	PrivateKey crypto.PrivateKey
+	PublicKey  crypto.PublicKey
+	Issuer     string        // Expected "iss" claim, set on issued tokens when missing
+	Audience   string        // Expected "aud" claim, set on issued tokens when missing
+	TTL        time.Duration // Lifetime of issued tokens, no "exp" claim is added when zero
+	Leeway     time.Duration // Clock skew tolerance for exp, nbf and iat validation
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+}
+
+/*
+Return the value of a claim as a unix timestamp
+*/
+func (c JWTClaims) time(name string) (int64, bool, error) {
+	value, ok := c[name]
+	if !ok {
+		return 0, false, nil
+	}
+	switch v := value.(type) {
+	case float64:
+		return int64(v), true, nil
+	case int64:
+		return v, true, nil
+	case int:
+		return int64(v), true, nil
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			return 0, true, ErrJWTMalformed
+		}
+		return n, true, nil
+	}
+	return 0, true, ErrJWTMalformed
+}
+
+/*
+Return true if the "aud" claim (string or list of strings) contains the audience
+*/
+func (c JWTClaims) hasAudience(audience string) bool {
+	switch v := c["aud"].(type) {
+	case string:
+		return v == audience
+	case []string:
+		for _, a := range v {
+			if a == audience {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func jwtEncode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func jwtHMAC(alg string, payload string, secretKey string) ([]byte, error) {
+	var fnc func() hash.Hash
+	switch alg {
+	case JWTAlgorithmHS256:
+		fnc = sha256.New
+	case JWTAlgorithmHS512:
+		fnc = sha512.New
+	default:
+		return nil, ErrJWTAlgorithm
+	}
+	if secretKey == "" {
+		return nil, ErrJWTKey
+	}
+	h := hmac.New(fnc, []byte(secretKey))
+	h.Write([]byte(payload))
+	return h.Sum(nil), nil
+}
+
+func jwtSign(cnf JWTConfig, payload string) ([]byte, error) {
+	switch cnf.Algorithm {
+	case JWTAlgorithmHS256, JWTAlgorithmHS512:
+		return jwtHMAC(cnf.Algorithm, payload, cnf.SecretKey)
+	case JWTAlgorithmRS256:
+		key, ok := cnf.PrivateKey.(*rsa.PrivateKey)
+		if !ok {
+			return nil, ErrJWTKey
+		}
+		sum := sha256.Sum256([]byte(payload))
+		return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	case JWTAlgorithmES256:
+		key, ok := cnf.PrivateKey.(*ecdsa.PrivateKey)
+		if !ok || key.Curve.Params().BitSize != 256 {
+			return nil, ErrJWTKey
+		}
+		sum := sha256.Sum256([]byte(payload))
+		r, s, err := ecdsa.Sign(rand.Reader, key, sum[:])
+		if err != nil {
+			return nil, err
+		}
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+		return signature, nil
+	}
+	return nil, ErrJWTAlgorithm
+}
+
+func jwtVerify(cnf JWTConfig, payload string, signature []byte) error {
+	if cnf.PublicKey == nil {
+		if key, ok := cnf.PrivateKey.(crypto.Signer); ok {
+			cnf.PublicKey = key.Public()
+		}
+	}
+	switch cnf.Algorithm {
+	case JWTAlgorithmHS256, JWTAlgorithmHS512:
+		expected, err := jwtHMAC(cnf.Algorithm, payload, cnf.SecretKey)
+		if err != nil {
+			return err
+		}
+		if !hmac.Equal(expected, signature) {
+			return ErrJWTSignature
+		}
+		return nil
+	case JWTAlgorithmRS256:
+		key, ok := cnf.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return ErrJWTKey
+		}
+		sum := sha256.Sum256([]byte(payload))
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], signature); err != nil {
+			return ErrJWTSignature
+		}
+		return nil
+	case JWTAlgorithmES256:
+		key, ok := cnf.PublicKey.(*ecdsa.PublicKey)
+		if !ok {
+			return ErrJWTKey
+		}
+		if len(signature) != 64 {
+			return ErrJWTSignature
+		}
+		sum := sha256.Sum256([]byte(payload))
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(key, sum[:], r, s) {
+			return ErrJWTSignature
+		}
+		return nil
+	}
+	return ErrJWTAlgorithm
+}
+
+/*
+Create a signed JSON Web Token with the claims. The "iat" claim is always set, "exp",
+"iss" and "aud" are added from the configuration when they are not present
+*/
+func NewJWT(claims JWTClaims, cnf JWTConfig) (string, error) {
+	now := time.Now()
+
+	payload := JWTClaims{}
+	for k, v := range claims {
+		payload[k] = v
+	}
+	payload["iat"] = now.Unix()
+	if _, ok := payload["exp"]; !ok && cnf.TTL > 0 {
+		payload["exp"] = now.Add(cnf.TTL).Unix()
+	}
+	if _, ok := payload["iss"]; !ok && cnf.Issuer != "" {
+		payload["iss"] = cnf.Issuer
+	}
+	if _, ok := payload["aud"]; !ok && cnf.Audience != "" {
+		payload["aud"] = cnf.Audience
+	}
+
+	header, err := json.Marshal(jwtHeader{Algorithm: cnf.Algorithm, Type: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := jwtEncode(header) + "." + jwtEncode(body)
+	signature, err := jwtSign(cnf, unsigned)
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + jwtEncode(signature), nil
+}
+
+/*
+Verify the signature of a JSON Web Token and validate its exp, nbf, iat, iss and aud
+claims, returning the token claims
+*/
+func ParseJWT(token string, cnf JWTConfig) (JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrJWTMalformed
+	}
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrJWTMalformed
+	}
+	header := jwtHeader{}
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return nil, ErrJWTMalformed
+	}
+	if header.Algorithm != cnf.Algorithm {
+		return nil, ErrJWTAlgorithm
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrJWTMalformed
+	}
+	if err := jwtVerify(cnf, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	rawClaims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrJWTMalformed
+	}
+	claims := JWTClaims{}
+	if err := json.Unmarshal(rawClaims, &claims); err != nil {
+		return nil, ErrJWTMalformed
+	}
+
+	if err := claims.validate(cnf, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func (c JWTClaims) validate(cnf JWTConfig, now time.Time) error {
+	leeway := int64(cnf.Leeway / time.Second)
+	unix := now.Unix()
+
+	if exp, ok, err := c.time("exp"); err != nil {
+		return err
+	} else if ok && unix > exp+leeway {
+		return ErrJWTExpired
+	}
+	if nbf, ok, err := c.time("nbf"); err != nil {
+		return err
+	} else if ok && unix < nbf-leeway {
+		return ErrJWTNotValidYet
+	}
+	if iat, ok, err := c.time("iat"); err != nil {
+		return err
+	} else if ok && unix < iat-leeway {
+		return ErrJWTIssuedAt
+	}
+	if cnf.Issuer != "" {
+		if iss, _ := c["iss"].(string); iss != cnf.Issuer {
+			return ErrJWTIssuer
+		}
+	}
+	if cnf.Audience != "" && !c.hasAudience(cnf.Audience) {
+		return ErrJWTAudience
+	}
+
+	return nil
+}
+
+/*
+Return the verified JWT claims stored in the context by MiddlewareJWT
+*/
+func JWTClaimsFromContext(ctx context.Context) (JWTClaims, bool) {
+	claims, ok := ctx.Value(jwtContextKey{}).(JWTClaims)
+	return claims, ok
+}
+
+/*
+Create a middleware that verifies the Bearer token of the Authorization header and
+places its claims in the request context. Invalid requests are rejected with a JSON
+error and status 401
+*/
+func MiddlewareJWT(cnf JWTConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(response http.ResponseWriter, request *http.Request) {
+				token := ParseAuthorizationHeader(request)
+				if token == "" {
+					RespondWithJSONError(response, http.StatusUnauthorized, ErrJWTMissing)
+					return
+				}
+				claims, err := ParseJWT(token, cnf)
+				if err != nil {
+					RespondWithJSONError(response, http.StatusUnauthorized, err)
+					return
+				}
+				ctx := context.WithValue(request.Context(), jwtContextKey{}, claims)
+				next.ServeHTTP(response, request.WithContext(ctx))
+			})
+	}
+}
@@ -0,0 +1,130 @@
+package rest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewJWT(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configs := []JWTConfig{
+		{Algorithm: JWTAlgorithmHS256, SecretKey: "4234kxzjcjj3@nxnxbcvsjfj"},
+		{Algorithm: JWTAlgorithmHS512, SecretKey: "4234kxzjcjj3@nxnxbcvsjfj"},
+		{Algorithm: JWTAlgorithmRS256, PrivateKey: rsaKey, PublicKey: &rsaKey.PublicKey},
+		{Algorithm: JWTAlgorithmES256, PrivateKey: ecKey, PublicKey: &ecKey.PublicKey},
+	}
+
+	for _, cnf := range configs {
+		cnf.TTL = time.Minute
+		cnf.Issuer = "go-api-service"
+		cnf.Audience = "tests"
+
+		token, err := NewJWT(JWTClaims{"sub": "1234"}, cnf)
+		if err != nil {
+			t.Fatalf("%s: NewJWT fail! error: %s", cnf.Algorithm, err)
+		}
+
+		claims, err := ParseJWT(token, cnf)
+		if err != nil {
+			t.Fatalf("%s: ParseJWT fail! error: %s", cnf.Algorithm, err)
+		}
+		if claims["sub"] != "1234" {
+			t.Errorf("%s: unexpected \"sub\" claim:\ngot  %v\nwant 1234", cnf.Algorithm, claims["sub"])
+		}
+
+		tampered := token[:len(token)-4] + "AAAA"
+		if _, err := ParseJWT(tampered, cnf); err == nil {
+			t.Errorf("%s: expected error for tampered token", cnf.Algorithm)
+		}
+	}
+}
+
+func TestParseJWT(t *testing.T) {
+	cnf := JWTConfig{Algorithm: JWTAlgorithmHS256, SecretKey: "4234kxzjcjj3@nxnxbcvsjfj"}
+	now := time.Now().Unix()
+
+	tests := []struct {
+		claims JWTClaims
+		cnf    JWTConfig
+		want   error
+	}{
+		{JWTClaims{"exp": now - 60}, cnf, ErrJWTExpired},
+		{JWTClaims{"nbf": now + 60}, cnf, ErrJWTNotValidYet},
+		{JWTClaims{"iss": "other"}, JWTConfig{Algorithm: cnf.Algorithm, SecretKey: cnf.SecretKey, Issuer: "me"}, ErrJWTIssuer},
+		{JWTClaims{"aud": []string{"a", "b"}}, JWTConfig{Algorithm: cnf.Algorithm, SecretKey: cnf.SecretKey, Audience: "c"}, ErrJWTAudience},
+		{JWTClaims{"aud": []string{"a", "b"}}, JWTConfig{Algorithm: cnf.Algorithm, SecretKey: cnf.SecretKey, Audience: "b"}, nil},
+		{JWTClaims{"exp": now - 5}, JWTConfig{Algorithm: cnf.Algorithm, SecretKey: cnf.SecretKey, Leeway: 30 * time.Second}, nil},
+	}
+
+	for i, test := range tests {
+		token, err := NewJWT(test.claims, cnf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ParseJWT(token, test.cnf); !errors.Is(err, test.want) {
+			t.Errorf("case %d: unexpected error:\ngot  %v\nwant %v", i, err, test.want)
+		}
+	}
+
+	token, _ := NewJWT(JWTClaims{}, cnf)
+	other := JWTConfig{Algorithm: JWTAlgorithmHS512, SecretKey: cnf.SecretKey}
+	if _, err := ParseJWT(token, other); !errors.Is(err, ErrJWTAlgorithm) {
+		t.Errorf("unexpected error:\ngot  %v\nwant %v", err, ErrJWTAlgorithm)
+	}
+
+	if _, err := ParseJWT("not-a-token", cnf); !errors.Is(err, ErrJWTMalformed) {
+		t.Errorf("unexpected error:\ngot  %v\nwant %v", err, ErrJWTMalformed)
+	}
+}
+
+func TestMiddlewareJWT(t *testing.T) {
+	cnf := JWTConfig{Algorithm: JWTAlgorithmHS256, SecretKey: "4234kxzjcjj3@nxnxbcvsjfj"}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := JWTClaimsFromContext(r.Context())
+		if !ok {
+			t.Errorf("expected JWT claims in request context")
+		}
+		RespondWithJSONMessage(w, http.StatusOK, claims["sub"].(string))
+	})
+	middle := MiddlewareJWT(cnf)(handler)
+
+	token, err := NewJWT(JWTClaims{"sub": "1234"}, cnf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/MiddlewareJWT", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resOk := httptest.NewRecorder()
+	middle.ServeHTTP(resOk, req)
+
+	expected := `{"message":"1234"}`
+	if resOk.Code != http.StatusOK || resOk.Body.String() != expected {
+		t.Errorf("handler returned unexpected response: \n\t got %v %v\n\twant %v %v", resOk.Code, resOk.Body.String(), http.StatusOK, expected)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/MiddlewareJWT", nil)
+	resFail := httptest.NewRecorder()
+	middle.ServeHTTP(resFail, req)
+
+	expected = `{"message":"authorization token required"}`
+	if resFail.Code != http.StatusUnauthorized || resFail.Body.String() != expected {
+		t.Errorf("handler returned unexpected response: \n\t got %v %v\n\twant %v %v", resFail.Code, resFail.Body.String(), http.StatusUnauthorized, expected)
+	}
+}
@@ -1,31 +1,41 @@
 package rest
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"runtime"
-	"strings"
 )
 
+const restrictedMessage = "the request endpoint is restricted"
+
 func MiddlewareAccessLog(next http.Handler) http.Handler {
 	return http.HandlerFunc(
 		func(response http.ResponseWriter, request *http.Request) {
-			log.Printf(
-				"PID %d, Routines %d - [%s] from IP: %s - URL: %s\n",
+			line := fmt.Sprintf(
+				"PID %d, Routines %d - [%s] from IP: %s - URL: %s",
 				os.Getpid(), runtime.NumGoroutine(),
 				request.Method, request.RemoteAddr, request.URL,
 			)
+			if id := RequestIDFromContext(request.Context()); id != "" {
+				line += " - Request ID: " + id
+			}
+			log.Println(line)
 			next.ServeHTTP(response, request)
 		})
 }
 
+/*
+Middleware that only allows requests from loopback addresses, IPv4 (127.0.0.0/8), IPv6
+(::1) or IPv4-mapped IPv6, and responds with 403 otherwise
+*/
 func MiddlewareRestrictToLocal(next http.Handler) http.Handler {
 	return http.HandlerFunc(
 		func(response http.ResponseWriter, request *http.Request) {
-			ip := request.RemoteAddr[:strings.LastIndex(request.RemoteAddr, ":")]
-			if ip != "127.0.0.1" {
-				RespondWithJSONMessage(response, http.StatusForbidden, "the request endpoint is restricted")
+			addr, ok := parseHostAddr(request.RemoteAddr)
+			if !ok || !addr.Unmap().IsLoopback() {
+				RespondWithJSONMessage(response, http.StatusForbidden, restrictedMessage)
 			} else {
 				next.ServeHTTP(response, request)
 			}
@@ -75,3 +75,37 @@ func TestMiddlewareRestrictToLocal(t *testing.T) {
 		t.Errorf("handler returned unexpected body: \n\t got %v\n\twant %v", resFail.Body.String(), expected)
 	}
 }
+
+func TestMiddlewareRestrictToLocalIPv6(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		RespondWithJSONMessage(w, http.StatusOK, "OK")
+	})
+	middle := MiddlewareRestrictToLocal(handler)
+
+	tests := []struct {
+		remoteAddr string
+		expected   int
+	}{
+		{"[::1]:12345", http.StatusOK},
+		{"[::ffff:127.0.0.1]:12345", http.StatusOK},
+		{"127.0.0.2:12345", http.StatusOK},
+		{"::1", http.StatusOK},
+		{"[fe80::1%eth0]:12345", http.StatusForbidden},
+		{"[2001:db8::1]:12345", http.StatusForbidden},
+		{"[::ffff:192.168.0.1]:12345", http.StatusForbidden},
+		{"localhost:12345", http.StatusForbidden},
+		{"", http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/MiddlewareRestrictToLocal", nil)
+		req.RemoteAddr = test.remoteAddr
+		res := httptest.NewRecorder()
+
+		middle.ServeHTTP(res, req)
+
+		if status := res.Code; status != test.expected {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", test.remoteAddr, status, test.expected)
+		}
+	}
+}
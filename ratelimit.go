@@ -0,0 +1,295 @@
+package rest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	RateLimitTokenBucket   = "token_bucket"
+	RateLimitSlidingWindow = "sliding_window"
+)
+
+const rateLimitSweepInterval = time.Minute
+
+/*
+Limit applied to each key: Limit requests per Window
+*/
+type RateLimitRule struct {
+	Algorithm string // RateLimitTokenBucket or RateLimitSlidingWindow
+	Limit     int
+	Window    time.Duration
+}
+
+/*
+Outcome of a request counted by a RateLimitStore
+*/
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int           // Requests left for the key
+	Reset      time.Duration // Time until the quota is fully restored (token bucket) or the window ends (sliding window)
+	RetryAfter time.Duration // Time until the next request is allowed, only when not allowed
+}
+
+/*
+Backend of the rate limiter counters. Implementations for shared backends (e.g. Redis)
+must apply the rule atomically per key
+*/
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, rule RateLimitRule) (RateLimitResult, error)
+}
+
+/*
+Return the key a request is limited by
+*/
+type RateLimitKeyFunc func(r *http.Request) string
+
+/*
+Rate limiter settings
+*/
+type RateLimitConfig struct {
+	Algorithm string           // One of RateLimit*, default RateLimitTokenBucket
+	Limit     int              // Requests allowed per Window, required
+	Window    time.Duration    // Default 1 minute
+	Name      string           // Prefix of the store keys, lets several limiters share a store
+	KeyFunc   RateLimitKeyFunc // Default RateLimitByRemoteAddr
+	Store     RateLimitStore   // Default a new MemoryRateLimitStore
+}
+
+/*
+Limit requests by the peer address of the connection
+*/
+func RateLimitByRemoteAddr(r *http.Request) string {
+	return "ip:" + remoteAddr(r)
+}
+
+/*
+Limit requests by the client IP returned by GetRealIPAddr. The forwarding headers are
+trusted from anyone, so clients can bypass the limit sending a different X-Real-Ip on
+each request. Behind reverse proxies use RateLimitByClientIP
+*/
+func RateLimitByIP(r *http.Request) string {
+	return "ip:" + GetRealIPAddr(r)
+}
+
+/*
+Limit requests by the client IP returned by the resolver
+*/
+func RateLimitByClientIP(resolver *ClientIPResolver) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		return "ip:" + resolver.ClientIP(r)
+	}
+}
+
+/*
+Limit requests by the hash of their bearer token, requests without a token are limited
+by their peer address
+*/
+func RateLimitByToken(r *http.Request) string {
+	token := ParseAuthorizationHeader(r)
+	if token == "" {
+		return RateLimitByRemoteAddr(r)
+	}
+	sum := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(sum[:])
+}
+
+type rateLimitEntry struct {
+	tokens  float64
+	last    time.Time
+	start   time.Time
+	prev    int
+	count   int
+	expires time.Time
+}
+
+/*
+In memory RateLimitStore for a single instance, stale keys are removed periodically
+*/
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	entries   map[string]*rateLimitEntry
+	nextSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{entries: map[string]*rateLimitEntry{}, now: time.Now}
+}
+
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, rule RateLimitRule) (RateLimitResult, error) {
+	if rule.Limit <= 0 || rule.Window <= 0 {
+		return RateLimitResult{}, fmt.Errorf("invalid rate limit rule: %d per %s", rule.Limit, rule.Window)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.After(s.nextSweep) {
+		for k, entry := range s.entries {
+			if now.After(entry.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.nextSweep = now.Add(rateLimitSweepInterval)
+	}
+
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &rateLimitEntry{tokens: float64(rule.Limit), last: now}
+		s.entries[key] = entry
+	}
+
+	switch rule.Algorithm {
+	case RateLimitSlidingWindow:
+		return entry.slidingWindow(now, rule), nil
+	case RateLimitTokenBucket, "":
+		return entry.tokenBucket(now, rule), nil
+	}
+	return RateLimitResult{}, fmt.Errorf("unknown rate limit algorithm: %s", rule.Algorithm)
+}
+
+/*
+Bucket of Limit tokens refilled at Limit per Window, each request takes a token
+*/
+func (e *rateLimitEntry) tokenBucket(now time.Time, rule RateLimitRule) RateLimitResult {
+	limit := float64(rule.Limit)
+	rate := limit / rule.Window.Seconds()
+
+	e.tokens = math.Min(limit, e.tokens+now.Sub(e.last).Seconds()*rate)
+	e.last = now
+	e.expires = now.Add(rule.Window)
+
+	result := RateLimitResult{Limit: rule.Limit}
+	if e.tokens >= 1 {
+		e.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - e.tokens) / rate)
+	}
+	result.Remaining = int(e.tokens)
+	result.Reset = seconds((limit - e.tokens) / rate)
+	return result
+}
+
+/*
+Counters of the current and previous fixed windows, the previous one weighted by its
+overlap with the sliding window ending now
+*/
+func (e *rateLimitEntry) slidingWindow(now time.Time, rule RateLimitRule) RateLimitResult {
+	start := now.Truncate(rule.Window)
+	if !start.Equal(e.start) {
+		if start.Sub(e.start) == rule.Window {
+			e.prev = e.count
+		} else {
+			e.prev = 0
+		}
+		e.count = 0
+		e.start = start
+	}
+	e.expires = start.Add(2 * rule.Window)
+
+	elapsed := now.Sub(start)
+	weight := 1 - float64(elapsed)/float64(rule.Window)
+	estimated := float64(e.prev)*weight + float64(e.count)
+	limit := float64(rule.Limit)
+
+	result := RateLimitResult{Limit: rule.Limit, Reset: rule.Window - elapsed}
+	if estimated+1 <= limit {
+		e.count++
+		estimated++
+		result.Allowed = true
+	} else if float64(e.count)+1 > limit {
+		// wait for the next window, where this window becomes the weighted previous one
+		wait := rule.Window - elapsed
+		if e.count > 0 {
+			wait += time.Duration(float64(rule.Window) * math.Max(0, 1-(limit-1)/float64(e.count)))
+		}
+		result.RetryAfter = wait
+	} else {
+		allowedAt := time.Duration(float64(rule.Window) * (1 - (limit-1-float64(e.count))/float64(e.prev)))
+		result.RetryAfter = allowedAt - elapsed
+	}
+	result.Remaining = int(math.Max(0, math.Floor(limit-estimated)))
+	return result
+}
+
+func seconds(value float64) time.Duration {
+	return time.Duration(value * float64(time.Second))
+}
+
+/*
+Round a duration up to whole seconds for the rate limit headers
+*/
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+/*
+Create a middleware that limits the requests of each key. Responses carry the
+RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers and
+limited requests are rejected with Retry-After, a JSON error and status 429. When the
+store fails the request is allowed and the error logged. Returns ErrRateLimitInvalid
+when the limit is not positive and ErrRateLimitAlgorithm for unknown algorithms
+*/
+func MiddlewareRateLimit(cnf RateLimitConfig) (func(http.Handler) http.Handler, error) {
+	if cnf.Limit <= 0 {
+		return nil, ErrRateLimitInvalid
+	}
+	if cnf.Algorithm == "" {
+		cnf.Algorithm = RateLimitTokenBucket
+	}
+	if cnf.Algorithm != RateLimitTokenBucket && cnf.Algorithm != RateLimitSlidingWindow {
+		return nil, ErrRateLimitAlgorithm
+	}
+	if cnf.Window <= 0 {
+		cnf.Window = time.Minute
+	}
+	if cnf.KeyFunc == nil {
+		cnf.KeyFunc = RateLimitByRemoteAddr
+	}
+	if cnf.Store == nil {
+		cnf.Store = NewMemoryRateLimitStore()
+	}
+	rule := RateLimitRule{Algorithm: cnf.Algorithm, Limit: cnf.Limit, Window: cnf.Window}
+	policy := fmt.Sprintf("%d;w=%s", cnf.Limit, ceilSeconds(cnf.Window))
+	prefix := ""
+	if cnf.Name != "" {
+		prefix = cnf.Name + ":"
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(response http.ResponseWriter, request *http.Request) {
+				result, err := cnf.Store.Take(request.Context(), prefix+cnf.KeyFunc(request), rule)
+				if err != nil {
+					log.Printf("rate limit store failed - Request ID: %s - %s\n", RequestIDFromContext(request.Context()), err)
+					next.ServeHTTP(response, request)
+					return
+				}
+
+				header := response.Header()
+				header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+				header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+				header.Set("RateLimit-Reset", ceilSeconds(result.Reset))
+				header.Set("RateLimit-Policy", policy)
+
+				if !result.Allowed {
+					header.Set("Retry-After", ceilSeconds(result.RetryAfter))
+					RespondWithJSONError(response, http.StatusTooManyRequests, ErrRateLimited)
+					return
+				}
+				next.ServeHTTP(response, request)
+			})
+	}, nil
+}
@@ -0,0 +1,177 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func newTestRateLimitStore() (*MemoryRateLimitStore, *testClock) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := NewMemoryRateLimitStore()
+	store.now = clock.Now
+	return store, clock
+}
+
+func TestMemoryRateLimitStoreTokenBucket(t *testing.T) {
+	store, clock := newTestRateLimitStore()
+	rule := RateLimitRule{Algorithm: RateLimitTokenBucket, Limit: 3, Window: 3 * time.Second}
+	ctx := context.Background()
+
+	for i := 2; i >= 0; i-- {
+		result, _ := store.Take(ctx, "a", rule)
+		if !result.Allowed || result.Remaining != i {
+			t.Errorf("unexpected result: %+v", result)
+		}
+	}
+	result, _ := store.Take(ctx, "a", rule)
+	if result.Allowed || result.RetryAfter != time.Second || result.Reset != 3*time.Second {
+		t.Errorf("unexpected limited result: %+v", result)
+	}
+	if result, _ := store.Take(ctx, "b", rule); !result.Allowed {
+		t.Errorf("keys are not limited independently: %+v", result)
+	}
+
+	clock.now = clock.now.Add(time.Second)
+	if result, _ := store.Take(ctx, "a", rule); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("token not refilled: %+v", result)
+	}
+
+	if _, err := store.Take(ctx, "a", RateLimitRule{Algorithm: "unknown", Limit: 1, Window: time.Second}); err == nil {
+		t.Errorf("unknown algorithm accepted")
+	}
+	if _, err := store.Take(ctx, "a", RateLimitRule{Limit: 0, Window: time.Second}); err == nil {
+		t.Errorf("invalid rule accepted")
+	}
+}
+
+func TestMemoryRateLimitStoreSlidingWindow(t *testing.T) {
+	store, clock := newTestRateLimitStore()
+	rule := RateLimitRule{Algorithm: RateLimitSlidingWindow, Limit: 4, Window: 10 * time.Second}
+	ctx := context.Background()
+
+	for i := 0; i < 4; i++ {
+		if result, _ := store.Take(ctx, "a", rule); !result.Allowed || result.Remaining != 3-i {
+			t.Errorf("unexpected result: %+v", result)
+		}
+	}
+	result, _ := store.Take(ctx, "a", rule)
+	if result.Allowed || result.Reset != 10*time.Second || result.RetryAfter != 12500*time.Millisecond {
+		t.Errorf("unexpected limited result: %+v", result)
+	}
+
+	// 5 seconds into the next window the previous 4 requests weight 2
+	clock.now = clock.now.Add(15 * time.Second)
+	for i := 0; i < 2; i++ {
+		if result, _ := store.Take(ctx, "a", rule); !result.Allowed {
+			t.Errorf("unexpected limited result: %+v", result)
+		}
+	}
+	result, _ = store.Take(ctx, "a", rule)
+	if result.Allowed || result.RetryAfter != 2500*time.Millisecond {
+		t.Errorf("unexpected limited result: %+v", result)
+	}
+
+	clock.now = clock.now.Add(time.Minute)
+	if result, _ := store.Take(ctx, "a", rule); !result.Allowed || result.Remaining != 3 {
+		t.Errorf("old windows not discarded: %+v", result)
+	}
+}
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(ctx context.Context, key string, rule RateLimitRule) (RateLimitResult, error) {
+	return RateLimitResult{}, errors.New("store unavailable")
+}
+
+func TestMiddlewareRateLimit(t *testing.T) {
+	store, _ := newTestRateLimitStore()
+	middleware, err := MiddlewareRateLimit(RateLimitConfig{Limit: 2, Window: time.Minute, KeyFunc: RateLimitByToken, Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		RespondWithJSONMessage(w, http.StatusOK, "OK")
+	}))
+
+	serve := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res
+	}
+
+	serve("first")
+	res := serve("first")
+	if res.Code != http.StatusOK || res.Header().Get("RateLimit-Limit") != "2" || res.Header().Get("RateLimit-Remaining") != "0" ||
+		res.Header().Get("RateLimit-Policy") != "2;w=60" {
+		t.Errorf("unexpected response: %d %v", res.Code, res.Header())
+	}
+
+	res = serve("first")
+	expected := `{"message":"too many requests"}`
+	if res.Code != http.StatusTooManyRequests || res.Header().Get("Retry-After") != "30" || res.Body.String() != expected {
+		t.Errorf("unexpected limited response: %d %v %s", res.Code, res.Header(), res.Body.String())
+	}
+
+	if res = serve("second"); res.Code != http.StatusOK {
+		t.Errorf("tokens are not limited independently: %d", res.Code)
+	}
+
+	middleware, _ = MiddlewareRateLimit(RateLimitConfig{Limit: 1, Store: failingRateLimitStore{}})
+	handler = middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+	if res.Code != http.StatusOK {
+		t.Errorf("request not allowed when the store fails: %d", res.Code)
+	}
+}
+
+func TestMiddlewareRateLimitRemoteAddr(t *testing.T) {
+	middleware, err := MiddlewareRateLimit(RateLimitConfig{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(xri string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Real-Ip", xri)
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res.Code
+	}
+
+	serve("10.0.0.1")
+	if code := serve("10.0.0.2"); code != http.StatusTooManyRequests {
+		t.Errorf("limit bypassed with a spoofed X-Real-Ip: %d", code)
+	}
+}
+
+func TestMiddlewareRateLimitInvalid(t *testing.T) {
+	tests := []struct {
+		cnf      RateLimitConfig
+		expected error
+	}{
+		{RateLimitConfig{}, ErrRateLimitInvalid},
+		{RateLimitConfig{Limit: -1}, ErrRateLimitInvalid},
+		{RateLimitConfig{Limit: 1, Algorithm: "leaky_bucket"}, ErrRateLimitAlgorithm},
+	}
+	for _, test := range tests {
+		if _, err := MiddlewareRateLimit(test.cnf); !errors.Is(err, test.expected) {
+			t.Errorf("%+v: unexpected error:\ngot  %v\nwant %v", test.cnf, err, test.expected)
+		}
+	}
+}
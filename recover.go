@@ -0,0 +1,61 @@
+package rest
+
+import (
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gorilla/mux"
+)
+
+/*
+Callback notified with the recovered value and stack trace of a handler panic
+*/
+type PanicReporter func(r *http.Request, recovered interface{}, stack []byte)
+
+/*
+Return the route template matched by the mux router, or the URL path otherwise
+*/
+func routeName(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return r.URL.Path
+}
+
+/*
+Create a middleware that recovers from handler panics, logs the stack trace with the
+request ID and route, notifies the optional reporter and responds with a JSON 500
+*/
+func MiddlewareRecover(reporter PanicReporter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(response http.ResponseWriter, request *http.Request) {
+				sw := newStatusWriter(response)
+				defer func() {
+					recovered := recover()
+					if recovered == nil {
+						return
+					}
+					if recovered == http.ErrAbortHandler {
+						panic(recovered)
+					}
+
+					stack := debug.Stack()
+					log.Printf(
+						"panic recovered - Request ID: %s - Route: %s - %v\n%s",
+						RequestIDFromContext(request.Context()), routeName(request), recovered, stack,
+					)
+					if reporter != nil {
+						reporter(request, recovered, stack)
+					}
+					if !sw.Written() {
+						RespondWithJSONError(sw, http.StatusInternalServerError, ErrInternalServer)
+					}
+				}()
+				next.ServeHTTP(sw, request)
+			})
+	}
+}
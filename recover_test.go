@@ -0,0 +1,47 @@
+package rest
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestMiddlewareRecover(t *testing.T) {
+	var str bytes.Buffer
+	log.SetOutput(&str)
+
+	var reported interface{}
+	reporter := func(r *http.Request, recovered interface{}, stack []byte) {
+		reported = recovered
+	}
+
+	router := mux.NewRouter()
+	router.Use(MiddlewareRequestID, MiddlewareRecover(reporter))
+	router.HandleFunc("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		panic("unexpected failure")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/items/10", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	if res.Code != http.StatusInternalServerError {
+		t.Errorf("handler returned wrong status code: got %v want %v", res.Code, http.StatusInternalServerError)
+	}
+	expected := `{"message":"internal server error","request_id":"abc-123"}`
+	if res.Body.String() != expected {
+		t.Errorf("handler returned unexpected body: \n\t got %v\n\twant %v", res.Body.String(), expected)
+	}
+	if reported != "unexpected failure" {
+		t.Errorf("unexpected reported value:\ngot  %v\nwant unexpected failure", reported)
+	}
+	if line := str.String(); !strings.Contains(line, "Request ID: abc-123 - Route: /items/{id}") || !strings.Contains(line, "goroutine") {
+		t.Errorf("log do not include request ID, route and stack trace: \n\t got %v", line)
+	}
+}
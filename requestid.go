@@ -0,0 +1,69 @@
+package rest
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+const requestIDMaxLength = 128
+
+type requestIDContextKey struct{}
+
+/*
+Generate a random UUID version 4 used as request ID
+*/
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+/*
+Return true if an incoming request ID is safe to be propagated to logs and headers
+*/
+func validRequestID(id string) bool {
+	if id == "" || len(id) > requestIDMaxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+Return the request ID stored in the context by MiddlewareRequestID
+*/
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+/*
+Middleware that takes the X-Request-ID header of the request, or generates a new ID,
+stores it in the request context and echoes it in the response header
+*/
+func MiddlewareRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(response http.ResponseWriter, request *http.Request) {
+			id := request.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = NewRequestID()
+			}
+
+			response.Header().Set(RequestIDHeader, id)
+			ctx := context.WithValue(request.Context(), requestIDContextKey{}, id)
+			next.ServeHTTP(response, request.WithContext(ctx))
+		})
+}
@@ -0,0 +1,73 @@
+package rest
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestNewRequestID(t *testing.T) {
+	id := NewRequestID()
+	match, _ := regexp.MatchString(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, id)
+	if !match {
+		t.Errorf("unexpected request ID format: %v", id)
+	}
+	if NewRequestID() == id {
+		t.Errorf("expected a different request ID on every call")
+	}
+}
+
+func TestMiddlewareRequestID(t *testing.T) {
+	var got string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = RequestIDFromContext(r.Context())
+		RespondWithJSONError(w, http.StatusBadRequest, errors.New("bad request"))
+	})
+	middle := MiddlewareRequestID(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/MiddlewareRequestID", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	res := httptest.NewRecorder()
+	middle.ServeHTTP(res, req)
+
+	if got != "abc-123" {
+		t.Errorf("unexpected context request ID:\ngot  %v\nwant abc-123", got)
+	}
+	if id := res.Header().Get(RequestIDHeader); id != "abc-123" {
+		t.Errorf("unexpected response request ID header:\ngot  %v\nwant abc-123", id)
+	}
+	expected := `{"message":"bad request","request_id":"abc-123"}`
+	if res.Body.String() != expected {
+		t.Errorf("handler returned unexpected body: \n\t got %v\n\twant %v", res.Body.String(), expected)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/MiddlewareRequestID", nil)
+	req.Header.Set(RequestIDHeader, "invalid id\n")
+	res = httptest.NewRecorder()
+	middle.ServeHTTP(res, req)
+
+	if got == "" || got == "invalid id\n" || res.Header().Get(RequestIDHeader) != got {
+		t.Errorf("expected a generated request ID, got %q", got)
+	}
+}
+
+func TestMiddlewareAccessLogRequestID(t *testing.T) {
+	var str bytes.Buffer
+	log.SetOutput(&str)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	middle := MiddlewareRequestID(MiddlewareAccessLog(handler))
+
+	req := httptest.NewRequest(http.MethodGet, "/MiddlewareAccessLog", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	middle.ServeHTTP(httptest.NewRecorder(), req)
+
+	if result := strings.TrimSuffix(str.String(), "\n"); !strings.HasSuffix(result, " - Request ID: abc-123") {
+		t.Errorf("log line do not include the request ID: \n\t got %v", result)
+	}
+}
@@ -17,10 +17,15 @@ func RespondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 }
 
 /*
-Encode a golang error in JSON format and write to the response writer
+Encode a golang error in JSON format and write to the response writer. The request ID
+set by MiddlewareRequestID is included in the body when present
 */
 func RespondWithJSONError(w http.ResponseWriter, code int, err error) {
-	RespondWithJSON(w, code, map[string]string{"message": err.Error()})
+	body := map[string]string{"message": err.Error()}
+	if id := w.Header().Get(RequestIDHeader); id != "" {
+		body["request_id"] = id
+	}
+	RespondWithJSON(w, code, body)
 }
 
 /*
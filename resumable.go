@@ -0,0 +1,511 @@
+package rest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const TusResumable = "1.0.0"
+
+const tusExtensions = "creation,expiration,termination"
+
+const defaultResumableExpiration = 24 * time.Hour
+
+/*
+Settings of the resumable uploads handlers
+*/
+type ResumableUploadConfig struct {
+	Folder     string        // Folder of the uploads, defaults to the temporal folder used by SaveTmpFileFromRequest
+	MaxSize    int64         // Maximum upload length in bytes, zero disables the check
+	Expiration time.Duration // Lifetime of an upload since its last chunk, default 24 hours
+	// Called by the request writing the last chunk. When it fails the upload is kept
+	// incomplete and a PATCH without content at the final offset calls it again.
+	// Completed uploads expire as well, the callback should move the file at upload.Path
+	// to its final destination
+	OnComplete func(r *http.Request, upload ResumableUpload) error
+}
+
+/*
+State of a resumable upload
+*/
+type ResumableUpload struct {
+	ID       string
+	Length   int64             // Total size in bytes declared on creation
+	Offset   int64             // Bytes received so far
+	Metadata map[string]string // Decoded Upload-Metadata sent on creation
+	Path     string            // File with the received content
+	Expires  time.Time
+	Complete bool // True once OnComplete succeeded
+}
+
+type resumableInfo struct {
+	Length   int64             `json:"length"`
+	Metadata map[string]string `json:"metadata"`
+	Expires  time.Time         `json:"expires"`
+	Complete bool              `json:"complete"`
+}
+
+/*
+Handlers of the tus resumable upload protocol (https://tus.io/protocols/resumable-upload)
+with the creation, expiration and termination extensions. Content is appended to a file
+per upload in the configured folder next to an "<id>.info" file with its state
+*/
+type ResumableUploads struct {
+	cnf   ResumableUploadConfig
+	mu    sync.Mutex
+	locks map[string]struct{}
+}
+
+func NewResumableUploads(cnf ResumableUploadConfig) (*ResumableUploads, error) {
+	if cnf.Folder == "" {
+		cnf.Folder = os.TempDir()
+	}
+	if cnf.Expiration <= 0 {
+		cnf.Expiration = defaultResumableExpiration
+	}
+	if err := os.MkdirAll(cnf.Folder, 0755); err != nil {
+		return nil, err
+	}
+
+	return &ResumableUploads{cnf: cnf, locks: map[string]struct{}{}}, nil
+}
+
+/*
+Register the handlers on the router: POST and OPTIONS on path, HEAD, PATCH, DELETE and
+OPTIONS on path + "/{id}"
+*/
+func (u *ResumableUploads) Register(router *mux.Router, path string) {
+	path = strings.TrimSuffix(path, "/")
+	router.Handle(path, HandlerFunc(u.Create)).Methods(http.MethodPost)
+	router.Handle(path, HandlerFunc(u.Options)).Methods(http.MethodOptions)
+	router.Handle(path+"/{id}", HandlerFunc(u.Head)).Methods(http.MethodHead)
+	router.Handle(path+"/{id}", HandlerFunc(u.Patch)).Methods(http.MethodPatch)
+	router.Handle(path+"/{id}", HandlerFunc(u.Delete)).Methods(http.MethodDelete)
+	router.Handle(path+"/{id}", HandlerFunc(u.Options)).Methods(http.MethodOptions)
+}
+
+/*
+Return the upload ID of the request, taken from the "id" route variable or the last
+segment of the URL path
+*/
+func resumableID(r *http.Request) string {
+	if id, ok := mux.Vars(r)["id"]; ok {
+		return id
+	}
+	return path.Base(r.URL.Path)
+}
+
+/*
+Return true for IDs generated by NewRequestID, so IDs from URLs can not name other files
+*/
+func validResumableID(id string) bool {
+	if len(id) != 36 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if c != '-' && (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+Decode an Upload-Metadata header: comma separated pairs of a key and its base64
+encoded value
+*/
+func parseResumableMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if key == "" || err != nil {
+			return nil, ErrResumableMetadata
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+func formatResumableMetadata(metadata map[string]string) string {
+	pairs := make([]string, 0, len(metadata))
+	for key, value := range metadata {
+		pairs = append(pairs, key+" "+base64.StdEncoding.EncodeToString([]byte(value)))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (u *ResumableUploads) lock(id string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if _, ok := u.locks[id]; ok {
+		return false
+	}
+	u.locks[id] = struct{}{}
+	return true
+}
+
+func (u *ResumableUploads) unlock(id string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	delete(u.locks, id)
+}
+
+func (u *ResumableUploads) dataPath(id string) string {
+	return filepath.Join(u.cnf.Folder, id)
+}
+
+func (u *ResumableUploads) infoPath(id string) string {
+	return filepath.Join(u.cnf.Folder, id+".info")
+}
+
+/*
+Write the state of an upload to a temporal file renamed over the "<id>.info" file, so
+readers and crashes never see a partially written state
+*/
+func (u *ResumableUploads) writeInfo(upload ResumableUpload) error {
+	data, err := json.Marshal(resumableInfo{Length: upload.Length, Metadata: upload.Metadata, Expires: upload.Expires, Complete: upload.Complete})
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(u.cnf.Folder, upload.ID+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), u.infoPath(upload.ID))
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
+
+/*
+Return the state of an upload, the offset is the size of the received content. Completed
+uploads whose file was moved by OnComplete report their whole length
+*/
+func (u *ResumableUploads) Upload(id string) (ResumableUpload, error) {
+	if !validResumableID(id) {
+		return ResumableUpload{}, ErrResumableNotFound
+	}
+
+	data, err := os.ReadFile(u.infoPath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ResumableUpload{}, ErrResumableNotFound
+	}
+	if err != nil {
+		return ResumableUpload{}, err
+	}
+	info := resumableInfo{}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return ResumableUpload{}, err
+	}
+
+	upload := ResumableUpload{ID: id, Length: info.Length, Metadata: info.Metadata, Path: u.dataPath(id), Expires: info.Expires, Complete: info.Complete}
+	if time.Now().After(upload.Expires) {
+		return upload, ErrResumableExpired
+	}
+
+	stat, err := os.Stat(upload.Path)
+	if errors.Is(err, fs.ErrNotExist) && upload.Complete {
+		upload.Offset = upload.Length
+		return upload, nil
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return ResumableUpload{}, ErrResumableNotFound
+	}
+	if err != nil {
+		return ResumableUpload{}, err
+	}
+	upload.Offset = stat.Size()
+
+	return upload, nil
+}
+
+/*
+Set the tus headers sent in every response and check the protocol version of the request
+*/
+func checkTusVersion(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Tus-Resumable", TusResumable)
+	if r.Header.Get("Tus-Resumable") != TusResumable {
+		w.Header().Set("Tus-Version", TusResumable)
+		return ErrResumableVersion
+	}
+	return nil
+}
+
+func setUploadHeaders(w http.ResponseWriter, upload ResumableUpload) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	w.Header().Set("Upload-Expires", upload.Expires.UTC().Format(http.TimeFormat))
+}
+
+/*
+Call OnComplete and record the upload as complete when it succeeds
+*/
+func (u *ResumableUploads) complete(r *http.Request, upload *ResumableUpload) error {
+	if u.cnf.OnComplete != nil {
+		if err := u.cnf.OnComplete(r, *upload); err != nil {
+			return err
+		}
+	}
+	upload.Complete = true
+	return u.writeInfo(*upload)
+}
+
+/*
+Describe the supported protocol version, extensions and maximum size
+*/
+func (u *ResumableUploads) Options(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Tus-Resumable", TusResumable)
+	w.Header().Set("Tus-Version", TusResumable)
+	w.Header().Set("Tus-Extension", tusExtensions)
+	if u.cnf.MaxSize > 0 {
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(u.cnf.MaxSize, 10))
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+/*
+Create an upload of the Upload-Length size and reply its URL in the Location header
+*/
+func (u *ResumableUploads) Create(w http.ResponseWriter, r *http.Request) error {
+	if err := checkTusVersion(w, r); err != nil {
+		return err
+	}
+
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		return ErrResumableLength
+	}
+	if u.cnf.MaxSize > 0 && length > u.cnf.MaxSize {
+		return ErrUploadTooLarge
+	}
+	metadata, err := parseResumableMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		return err
+	}
+
+	upload := ResumableUpload{
+		ID:       NewRequestID(),
+		Length:   length,
+		Metadata: metadata,
+		Expires:  time.Now().Add(u.cnf.Expiration),
+	}
+	upload.Path = u.dataPath(upload.ID)
+
+	file, err := os.OpenFile(upload.Path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	file.Close()
+	if err := u.writeInfo(upload); err != nil {
+		os.Remove(upload.Path)
+		return err
+	}
+
+	if length == 0 {
+		if err := u.complete(r, &upload); err != nil {
+			u.remove(upload.ID)
+			return err
+		}
+	}
+
+	w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/")+"/"+upload.ID)
+	setUploadHeaders(w, upload)
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+/*
+Reply the offset and length of an upload
+*/
+func (u *ResumableUploads) Head(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Cache-Control", "no-store")
+	if err := checkTusVersion(w, r); err != nil {
+		return err
+	}
+
+	upload, err := u.Upload(resumableID(r))
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	if len(upload.Metadata) > 0 {
+		w.Header().Set("Upload-Metadata", formatResumableMetadata(upload.Metadata))
+	}
+	setUploadHeaders(w, upload)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+/*
+Append the request body to an upload at the Upload-Offset position. The bytes received
+before a connection failure are kept so the client can resume from the new offset, the
+upload is completed by the request that writes its last byte or, when completing it
+failed, by a later request at the final offset
+*/
+func (u *ResumableUploads) Patch(w http.ResponseWriter, r *http.Request) error {
+	if err := checkTusVersion(w, r); err != nil {
+		return err
+	}
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		return ErrResumableContentType
+	}
+
+	id := resumableID(r)
+	if !u.lock(id) {
+		return ErrResumableLocked
+	}
+	defer u.unlock(id)
+
+	upload, err := u.Upload(id)
+	if err != nil {
+		return err
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset != upload.Offset {
+		return ErrResumableOffset
+	}
+
+	if upload.Offset < upload.Length {
+		file, err := os.OpenFile(upload.Path, os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		written, err := io.Copy(file, http.MaxBytesReader(nil, r.Body, upload.Length-upload.Offset))
+		if err != nil && errors.Is(uploadError(err), ErrUploadTooLarge) {
+			file.Truncate(upload.Offset)
+			written = 0
+		}
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+
+		upload.Offset += written
+		upload.Expires = time.Now().Add(u.cnf.Expiration)
+		if infoErr := u.writeInfo(upload); err == nil {
+			err = infoErr
+		}
+		if err != nil {
+			return uploadError(err)
+		}
+	} else if r.ContentLength > 0 {
+		return ErrUploadTooLarge
+	}
+
+	if upload.Offset == upload.Length && !upload.Complete {
+		if err := u.complete(r, &upload); err != nil {
+			return err
+		}
+	}
+
+	setUploadHeaders(w, upload)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+/*
+Terminate an upload removing its files
+*/
+func (u *ResumableUploads) Delete(w http.ResponseWriter, r *http.Request) error {
+	if err := checkTusVersion(w, r); err != nil {
+		return err
+	}
+
+	id := resumableID(r)
+	if !u.lock(id) {
+		return ErrResumableLocked
+	}
+	defer u.unlock(id)
+
+	if _, err := u.Upload(id); err != nil && !errors.Is(err, ErrResumableExpired) {
+		return err
+	}
+	u.remove(id)
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (u *ResumableUploads) remove(id string) {
+	os.Remove(u.dataPath(id))
+	os.Remove(u.infoPath(id))
+}
+
+/*
+Remove the expired uploads, returning how many were removed
+*/
+func (u *ResumableUploads) Cleanup() (int, error) {
+	entries, err := os.ReadDir(u.cnf.Folder)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".info")
+		if !ok || !validResumableID(id) || !u.lock(id) {
+			continue
+		}
+		if _, err := u.Upload(id); errors.Is(err, ErrResumableExpired) || errors.Is(err, ErrResumableNotFound) {
+			u.remove(id)
+			removed++
+		}
+		u.unlock(id)
+	}
+	return removed, nil
+}
+
+/*
+Run Cleanup every interval until the context is done
+*/
+func (u *ResumableUploads) CleanupEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := u.Cleanup(); err != nil {
+				log.Printf("resumable uploads cleanup failed: %s\n", err)
+			}
+		}
+	}
+}
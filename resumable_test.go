@@ -0,0 +1,194 @@
+package rest
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func newResumableRequest(method string, target string, body []byte, headers map[string]string) *http.Request {
+	r := httptest.NewRequest(method, target, bytes.NewReader(body))
+	r.Header.Set("Tus-Resumable", TusResumable)
+	for key, value := range headers {
+		r.Header.Set(key, value)
+	}
+	return r
+}
+
+func TestResumableUploads(t *testing.T) {
+	var completed ResumableUpload
+	var content []byte
+	uploads, err := NewResumableUploads(ResumableUploadConfig{
+		Folder:  t.TempDir(),
+		MaxSize: 1024,
+		OnComplete: func(r *http.Request, upload ResumableUpload) error {
+			completed = upload
+			data, err := os.ReadFile(upload.Path)
+			content = data
+			return err
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter()
+	uploads.Register(router, "/files")
+
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve(newResumableRequest(http.MethodOptions, "/files", nil, nil))
+	if w.Code != http.StatusNoContent || w.Header().Get("Tus-Version") != TusResumable || w.Header().Get("Tus-Max-Size") != "1024" {
+		t.Errorf("unexpected OPTIONS response: %d %v", w.Code, w.Header())
+	}
+
+	w = serve(newResumableRequest(http.MethodPost, "/files", nil, map[string]string{"Upload-Length": "2048"}))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("unexpected status for a too large upload: %d", w.Code)
+	}
+	w = serve(httptest.NewRequest(http.MethodPost, "/files", nil))
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("unexpected status without Tus-Resumable: %d", w.Code)
+	}
+
+	w = serve(newResumableRequest(http.MethodPost, "/files", nil, map[string]string{
+		"Upload-Length":   "11",
+		"Upload-Metadata": "filename aGVsbG8udHh0,empty",
+	}))
+	location := w.Header().Get("Location")
+	if w.Code != http.StatusCreated || !strings.HasPrefix(location, "/files/") {
+		t.Fatalf("unexpected POST response: %d %v", w.Code, w.Header())
+	}
+
+	patch := func(offset int, body string) *httptest.ResponseRecorder {
+		return serve(newResumableRequest(http.MethodPatch, location, []byte(body), map[string]string{
+			"Content-Type":  "application/offset+octet-stream",
+			"Upload-Offset": strconv.Itoa(offset),
+		}))
+	}
+
+	if w = patch(0, "hello "); w.Code != http.StatusNoContent || w.Header().Get("Upload-Offset") != "6" {
+		t.Errorf("unexpected PATCH response: %d %v", w.Code, w.Header())
+	}
+	if w = patch(3, "world"); w.Code != http.StatusConflict {
+		t.Errorf("unexpected status for a wrong offset: %d", w.Code)
+	}
+	if w = patch(6, "world and more"); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("unexpected status for a chunk past the length: %d", w.Code)
+	}
+
+	w = serve(newResumableRequest(http.MethodHead, location, nil, nil))
+	if w.Code != http.StatusOK || w.Header().Get("Upload-Offset") != "6" || w.Header().Get("Upload-Length") != "11" ||
+		w.Header().Get("Upload-Metadata") != "empty ,filename aGVsbG8udHh0" {
+		t.Errorf("unexpected HEAD response: %d %v", w.Code, w.Header())
+	}
+
+	if w = patch(6, "world"); w.Code != http.StatusNoContent || w.Header().Get("Upload-Offset") != "11" {
+		t.Errorf("unexpected PATCH response: %d %v", w.Code, w.Header())
+	}
+	if string(content) != "hello world" || completed.Metadata["filename"] != "hello.txt" {
+		t.Errorf("unexpected completed upload: %+v %q", completed, content)
+	}
+
+	if w = serve(newResumableRequest(http.MethodDelete, location, nil, nil)); w.Code != http.StatusNoContent {
+		t.Errorf("unexpected DELETE response: %d", w.Code)
+	}
+	if w = serve(newResumableRequest(http.MethodHead, location, nil, nil)); w.Code != http.StatusNotFound {
+		t.Errorf("unexpected status for a deleted upload: %d", w.Code)
+	}
+	if w = serve(newResumableRequest(http.MethodHead, "/files/..", nil, nil)); w.Code == http.StatusOK {
+		t.Errorf("unexpected status for an invalid ID: %d", w.Code)
+	}
+}
+
+func TestResumableUploadsCleanup(t *testing.T) {
+	dir := t.TempDir()
+	uploads, err := NewResumableUploads(ResumableUploadConfig{Folder: dir, Expiration: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	uploads.Create(w, newResumableRequest(http.MethodPost, "/files", nil, map[string]string{"Upload-Length": "10"}))
+	id := strings.TrimPrefix(w.Header().Get("Location"), "/files/")
+
+	time.Sleep(5 * time.Millisecond)
+	if _, err := uploads.Upload(id); !errors.Is(err, ErrResumableExpired) {
+		t.Errorf("unexpected error:\ngot  %v\nwant %v", err, ErrResumableExpired)
+	}
+
+	removed, err := uploads.Cleanup()
+	if err != nil || removed != 1 {
+		t.Errorf("unexpected Cleanup result: %d %v", removed, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expired upload files were not removed: %v", entries)
+	}
+}
+
+func TestResumableUploadsCompleteRetry(t *testing.T) {
+	dir := t.TempDir()
+	dest := dir + "/done.txt"
+	calls := 0
+	uploads, err := NewResumableUploads(ResumableUploadConfig{
+		Folder: dir,
+		OnComplete: func(r *http.Request, upload ResumableUpload) error {
+			if calls++; calls == 1 {
+				return errors.New("storage unavailable")
+			}
+			return os.Rename(upload.Path, dest)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter()
+	uploads.Register(router, "/files")
+
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve(newResumableRequest(http.MethodPost, "/files", nil, map[string]string{"Upload-Length": "5"}))
+	location := w.Header().Get("Location")
+	patch := func(offset int, body string) *httptest.ResponseRecorder {
+		return serve(newResumableRequest(http.MethodPatch, location, []byte(body), map[string]string{
+			"Content-Type":  "application/offset+octet-stream",
+			"Upload-Offset": strconv.Itoa(offset),
+		}))
+	}
+
+	if w = patch(0, "hello"); w.Code != http.StatusInternalServerError {
+		t.Errorf("unexpected status for a failed completion: %d", w.Code)
+	}
+	if upload, err := uploads.Upload(strings.TrimPrefix(location, "/files/")); err != nil || upload.Offset != 5 || upload.Complete {
+		t.Errorf("unexpected upload after a failed completion: %+v %v", upload, err)
+	}
+
+	if w = patch(5, ""); w.Code != http.StatusNoContent || w.Header().Get("Upload-Offset") != "5" {
+		t.Errorf("unexpected PATCH response: %d %v", w.Code, w.Header())
+	}
+	if content, _ := os.ReadFile(dest); string(content) != "hello" {
+		t.Errorf("unexpected completed file content: %q", content)
+	}
+
+	if w = patch(5, ""); w.Code != http.StatusNoContent || calls != 2 {
+		t.Errorf("unexpected PATCH of a completed upload: %d, OnComplete called %d times", w.Code, calls)
+	}
+	if w = serve(newResumableRequest(http.MethodHead, location, nil, nil)); w.Code != http.StatusOK || w.Header().Get("Upload-Offset") != "5" {
+		t.Errorf("unexpected HEAD response: %d %v", w.Code, w.Header())
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -38,7 +39,7 @@ func main() {
 	r.HandleFunc("/sleep-10-seconds", sleep2seconds).Methods("GET")
 	r.NotFoundHandler = http.HandlerFunc(NotFound)
 
-	if err := srv.ListenAndServe(); err != nil {
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, ApiService.ErrGracefullShutdown) {
 		fmt.Println(err.Error())
 	}
 }
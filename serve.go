@@ -0,0 +1,125 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+/*
+Options of the files served by ServeFile
+*/
+type ServeFileConfig struct {
+	DownloadName string // File name sent in Content-Disposition, defaults to the base name of the file
+	Inline       bool   // Let the browser display the file instead of downloading it
+}
+
+/*
+Encode a file name as an RFC 5987 ext-value for the filename* parameter
+*/
+func encodeDispositionName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+/*
+Return an RFC 6266 Content-Disposition value with an ASCII fallback filename and the
+UTF-8 encoded filename* parameter
+*/
+func contentDisposition(disposition string, name string) string {
+	fallback := []rune(FixFileName(name))
+	for i, c := range fallback {
+		if c > 0x7e {
+			fallback[i] = '_'
+		}
+	}
+	return fmt.Sprintf("%s; filename=\"%s\"; filename*=UTF-8''%s", disposition, string(fallback), encodeDispositionName(name))
+}
+
+/*
+Return the path of name inside root, rejecting names or symbolic links that resolve
+outside of it
+*/
+func jailedPath(root string, name string) (string, error) {
+	if root == "" {
+		root = "."
+	}
+	file, err := NewLocalStorage(root).path(name)
+	if err != nil {
+		return "", err
+	}
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	realFile, err := filepath.EvalSymlinks(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", ErrStorageNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(realRoot, realFile); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", ErrStorageInvalidKey
+	}
+
+	return realFile, nil
+}
+
+/*
+Serve the file name stored in the root folder. Names can not escape root, Range and
+conditional requests are handled by http.ServeContent with an ETag built from the file
+size and modification time, and the Content-Disposition header carries the download name
+*/
+func ServeFile(w http.ResponseWriter, r *http.Request, root string, name string, cnf ServeFileConfig) error {
+	filename, err := jailedPath(root, name)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrStorageNotFound
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return ErrStorageNotFound
+	}
+
+	downloadName := cnf.DownloadName
+	if downloadName == "" {
+		downloadName = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	}
+	disposition := "attachment"
+	if cnf.Inline {
+		disposition = "inline"
+	}
+
+	w.Header().Set("ETag", fmt.Sprintf("\"%x-%x\"", info.Size(), info.ModTime().UnixNano()))
+	w.Header().Set("Content-Disposition", contentDisposition(disposition, downloadName))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, downloadName, info.ModTime(), file)
+	return nil
+}
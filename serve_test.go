@@ -0,0 +1,65 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServeFile(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "files")
+	os.MkdirAll(root, 0755)
+	os.WriteFile(filepath.Join(root, "report.txt"), []byte("hello world"), 0644)
+	os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0644)
+	os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(root, "link.txt"))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/files/report.txt", nil)
+	if err := ServeFile(w, r, root, "report.txt", ServeFileConfig{DownloadName: "informe añual.txt"}); err != nil {
+		t.Fatalf("ServeFile fail! error: %s", err)
+	}
+	etag := w.Header().Get("ETag")
+	want := `attachment; filename="informe-a_ual.txt"; filename*=UTF-8''informe%20a%C3%B1ual.txt`
+	if w.Code != http.StatusOK || w.Body.String() != "hello world" || etag == "" || w.Header().Get("Content-Disposition") != want {
+		t.Errorf("unexpected response: %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/files/report.txt", nil)
+	r.Header.Set("Range", "bytes=6-")
+	ServeFile(w, r, root, "report.txt", ServeFileConfig{Inline: true})
+	if w.Code != http.StatusPartialContent || w.Body.String() != "world" ||
+		w.Header().Get("Content-Disposition") != `inline; filename="report.txt"; filename*=UTF-8''report.txt` {
+		t.Errorf("unexpected range response: %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/files/report.txt", nil)
+	r.Header.Set("If-None-Match", etag)
+	ServeFile(w, r, root, "report.txt", ServeFileConfig{})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("unexpected status for a matching ETag: %d", w.Code)
+	}
+
+	tests := []struct {
+		name string
+		want error
+	}{
+		{"../secret.txt", ErrStorageInvalidKey},
+		{"..\\secret.txt", ErrStorageInvalidKey},
+		{"link.txt", ErrStorageInvalidKey},
+		{"missing.txt", ErrStorageNotFound},
+		{"/", ErrStorageInvalidKey},
+	}
+	for _, test := range tests {
+		w = httptest.NewRecorder()
+		err := ServeFile(w, httptest.NewRequest(http.MethodGet, "/", nil), root, test.name, ServeFileConfig{})
+		if !errors.Is(err, test.want) {
+			t.Errorf("%s: unexpected error:\ngot  %v\nwant %v", test.name, err, test.want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package rest
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net/http"
@@ -14,24 +15,48 @@ import (
 )
 
 type Service struct {
-	Address         string
-	WriteTimeout    time.Duration
-	ReadTimeout     time.Duration
-	ShutdownTimeout time.Duration
-	srv             *http.Server
-	router          *mux.Router
+	Address            string
+	WriteTimeout       time.Duration
+	ReadTimeout        time.Duration
+	ShutdownTimeout    time.Duration
+	CertFile           string
+	KeyFile            string
+	ClientCAFile       string
+	TLSMinVersion      uint16
+	TLSCipherSuites    []uint16
+	CertReloadInterval time.Duration
+	CORS               *CORSConfig
+	ErrorFormat        string
+	srv                *http.Server
+	router             *mux.Router
 }
 
 type ServiceConfig struct {
-	Interface       string
-	Port            int
-	ShutdownTimeout time.Duration
-	WriteTimeout    time.Duration
-	ReadTimeout     time.Duration
+	Interface          string // Default "127.0.0.1" (loopback only), "0.0.0.0" binds every interface
+	Port               int    // Default 1332
+	ShutdownTimeout    time.Duration
+	WriteTimeout       time.Duration
+	ReadTimeout        time.Duration
+	CertFile           string        // PEM certificate used by ListenAndServeTLS
+	KeyFile            string        // PEM private key used by ListenAndServeTLS
+	ClientCAFile       string        // PEM CA bundle, enables mutual TLS when set
+	TLSMinVersion      uint16        // Minimum TLS version, default tls.VersionTLS12
+	TLSCipherSuites    []uint16      // Allowed cipher suites for TLS 1.2 and lower, Go defaults when empty
+	CertReloadInterval time.Duration // Certificate files change check interval
+	CORS               *CORSConfig   // CORS policy applied to every route, disabled when nil
+	ErrorFormat        string        // ErrorFormatJSON or ErrorFormatProblem for the errors of HandlerFunc handlers, default the ErrorFormat variable
 }
 
 func NewService(cnf ServiceConfig) Service {
 
+	if cnf.Interface == "" {
+		cnf.Interface = "127.0.0.1"
+	}
+
+	if cnf.Port == 0 {
+		cnf.Port = 1332
+	}
+
 	if cnf.ShutdownTimeout == 0 {
 		cnf.ShutdownTimeout = time.Duration(30) * time.Second
 	}
@@ -44,16 +69,48 @@ func NewService(cnf ServiceConfig) Service {
 		cnf.ReadTimeout = time.Duration(30) * time.Second
 	}
 
+	if cnf.TLSMinVersion == 0 {
+		cnf.TLSMinVersion = tls.VersionTLS12
+	}
+
+	if cnf.CertReloadInterval == 0 {
+		cnf.CertReloadInterval = time.Duration(30) * time.Second
+	}
+
 	srv := Service{
-		Address:         fmt.Sprintf("%v:%v", cnf.Interface, cnf.Port),
-		ShutdownTimeout: cnf.ShutdownTimeout,
-		WriteTimeout:    cnf.WriteTimeout,
-		ReadTimeout:     cnf.ReadTimeout,
-		router:          mux.NewRouter(),
+		Address:            fmt.Sprintf("%v:%v", cnf.Interface, cnf.Port),
+		ShutdownTimeout:    cnf.ShutdownTimeout,
+		WriteTimeout:       cnf.WriteTimeout,
+		ReadTimeout:        cnf.ReadTimeout,
+		CertFile:           cnf.CertFile,
+		KeyFile:            cnf.KeyFile,
+		ClientCAFile:       cnf.ClientCAFile,
+		TLSMinVersion:      cnf.TLSMinVersion,
+		TLSCipherSuites:    cnf.TLSCipherSuites,
+		CertReloadInterval: cnf.CertReloadInterval,
+		CORS:               cnf.CORS,
+		ErrorFormat:        cnf.ErrorFormat,
+		router:             mux.NewRouter(),
+	}
+
+	if srv.ErrorFormat != "" {
+		format := srv.ErrorFormat
+		srv.router.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(
+				func(response http.ResponseWriter, request *http.Request) {
+					ctx := context.WithValue(request.Context(), errorFormatContextKey{}, format)
+					next.ServeHTTP(response, request.WithContext(ctx))
+				})
+		})
+	}
+
+	var handler http.Handler = srv.router
+	if srv.CORS != nil {
+		handler = NewCORS(*srv.CORS).Router(srv.router)
 	}
 
 	srv.srv = &http.Server{
-		Handler:      srv.router,
+		Handler:      handler,
 		Addr:         srv.Address,
 		WriteTimeout: srv.WriteTimeout,
 		ReadTimeout:  srv.ReadTimeout,
@@ -66,14 +123,6 @@ func (s *Service) Router() *mux.Router {
 	return s.router
 }
 
-func stopChannel() (chan os.Signal, func()) {
-	stopCh := make(chan os.Signal, 1)
-	signal.Notify(stopCh, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
-	return stopCh, func() {
-		close(stopCh)
-	}
-}
-
 func shutdown(ctx context.Context, server *http.Server, timeout time.Duration) error {
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
@@ -81,16 +130,51 @@ func shutdown(ctx context.Context, server *http.Server, timeout time.Duration) e
 	return server.Shutdown(ctx)
 }
 
-func (s *Service) ListenAndServe() error {
-	go func(srv *http.Server) {
-		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			panic(err)
+/*
+Run the listen function until it fails or the context is done. Startup errors are
+returned as they are, a clean stop returns ErrGracefullShutdown
+*/
+func (s *Service) serve(ctx context.Context, listen func() error) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- listen()
+	}()
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return ErrGracefullShutdown
 		}
-	}(s.srv)
-	stopCh, closeCh := stopChannel()
-	defer closeCh()
+		return err
+	case <-ctx.Done():
+	}
+
+	if err := shutdown(context.Background(), s.srv, s.ShutdownTimeout); err != nil {
+		return err
+	}
+	if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return ErrGracefullShutdown
+}
 
-	<-stopCh
+/*
+Start listening for requests until the process receives SIGINT or SIGTERM. Returns
+ErrGracefullShutdown when the service stops cleanly or the error that prevented it
+from starting
+*/
+func (s *Service) ListenAndServe() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return s.ListenAndServeContext(ctx)
+}
 
-	return shutdown(context.Background(), s.srv, s.ShutdownTimeout)
+/*
+Start listening for requests until the context is cancelled. Returns ErrGracefullShutdown
+when the service stops cleanly or the error that prevented it from starting
+*/
+func (s *Service) ListenAndServeContext(ctx context.Context) error {
+	return s.serve(ctx, s.srv.ListenAndServe)
 }
@@ -2,16 +2,30 @@ package rest
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"testing"
 	"time"
 )
 
+/*
+Return a free local port, the listener is closed so the service can bind it
+*/
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
 func TestNewService(t *testing.T) {
 
 	cnf := ServiceConfig{
 		Interface: "localhost",
-		Port:      9999,
+		Port:      freePort(t),
 	}
 
 	srv := NewService(cnf)
@@ -42,24 +56,41 @@ func TestNewService(t *testing.T) {
 
 func TestListenAndServe(t *testing.T) {
 	cnf := ServiceConfig{
-		Interface:       "localhost",
-		Port:            9999,
+		Interface:       "127.0.0.1",
+		Port:            freePort(t),
 		ShutdownTimeout: 1 * time.Second,
 	}
 
 	srv := NewService(cnf)
 
-	go func(s *Service) {
-		err := srv.ListenAndServe()
-		if err != nil {
-			t.Errorf("Service Listeng return unexpected error:\ngot  %s\nwant NoError", err)
-		}
-	}(&srv)
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServeContext(ctx)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
 
-	err := shutdown(context.Background(), srv.srv, srv.ShutdownTimeout)
+	if err := <-errCh; !errors.Is(err, ErrGracefullShutdown) {
+		t.Errorf("Service Listen return unexpected error:\ngot  %v\nwant %v", err, ErrGracefullShutdown)
+	}
+}
+
+func TestListenAndServeStartupError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
-		t.Errorf("Service shutdown return unexpected error:\ngot  %s\nwant NoError", err)
+		t.Fatal(err)
 	}
+	defer l.Close()
 
-	// syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+	srv := NewService(ServiceConfig{
+		Interface: "127.0.0.1",
+		Port:      l.Addr().(*net.TCPAddr).Port,
+	})
+
+	err = srv.ListenAndServeContext(context.Background())
+	if err == nil || errors.Is(err, ErrGracefullShutdown) {
+		t.Errorf("Service Listen return unexpected error:\ngot  %v\nwant address already in use", err)
+	}
 }
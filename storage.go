@@ -0,0 +1,283 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Metadata of a stored file
+*/
+type StorageObject struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+/*
+Backend used by the upload helpers to keep files. Keys are slash separated paths,
+implementations for object stores (e.g. S3 compatible services) only need to map
+them to object names
+*/
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) (StorageObject, error)
+	Get(ctx context.Context, key string) (io.ReadCloser, StorageObject, error)
+	Delete(ctx context.Context, key string) error
+	Stat(ctx context.Context, key string) (StorageObject, error)
+}
+
+/*
+Optional Storage extension used to move stored files without copying them, e.g. when
+uploads are content addressed
+*/
+type StorageRenamer interface {
+	Rename(ctx context.Context, from string, to string) error
+}
+
+/*
+Storage on the local file system. Keys are paths relative to Root and can not escape
+it; when Root is empty keys are used as file system paths
+*/
+type LocalStorage struct {
+	Root string
+}
+
+func NewLocalStorage(root string) *LocalStorage {
+	return &LocalStorage{Root: root}
+}
+
+func (s *LocalStorage) path(key string) (string, error) {
+	if s.Root == "" {
+		if key == "" {
+			return "", ErrStorageInvalidKey
+		}
+		return key, nil
+	}
+
+	normalized := strings.ReplaceAll(key, "\\", "/")
+	clean := path.Clean("/" + normalized)
+	if clean == "/" || strings.Contains(key, "\x00") {
+		return "", ErrStorageInvalidKey
+	}
+	for _, segment := range strings.Split(normalized, "/") {
+		if segment == ".." {
+			return "", ErrStorageInvalidKey
+		}
+	}
+
+	return filepath.Join(s.Root, filepath.FromSlash(clean)), nil
+}
+
+func (s *LocalStorage) object(key string, info fs.FileInfo) StorageObject {
+	return StorageObject{
+		Key:         key,
+		Size:        info.Size(),
+		ContentType: mime.TypeByExtension(filepath.Ext(key)),
+		ModTime:     info.ModTime(),
+	}
+}
+
+/*
+Write the file to a temporal file in the destination folder and rename it once
+complete, so a failed copy never leaves a partial file
+*/
+func (s *LocalStorage) Put(ctx context.Context, key string, r io.Reader, contentType string) (StorageObject, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return StorageObject{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return StorageObject{}, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return StorageObject{}, err
+	}
+	size, err := io.Copy(tmp, r)
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return StorageObject{}, err
+	}
+
+	return StorageObject{Key: key, Size: size, ContentType: contentType, ModTime: time.Now()}, nil
+}
+
+func (s *LocalStorage) Get(ctx context.Context, key string) (io.ReadCloser, StorageObject, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, StorageObject{}, err
+	}
+	file, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, StorageObject{}, ErrStorageNotFound
+	}
+	if err != nil {
+		return nil, StorageObject{}, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, StorageObject{}, err
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, StorageObject{}, ErrStorageNotFound
+	}
+	return file, s.object(key, info), nil
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStorage) Rename(ctx context.Context, from string, to string) error {
+	src, err := s.path(from)
+	if err != nil {
+		return err
+	}
+	dst, err := s.path(to)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); errors.Is(err, fs.ErrNotExist) {
+		return ErrStorageNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStorage) Stat(ctx context.Context, key string) (StorageObject, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return StorageObject{}, err
+	}
+	info, err := os.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && info.IsDir()) {
+		return StorageObject{}, ErrStorageNotFound
+	}
+	if err != nil {
+		return StorageObject{}, err
+	}
+	return s.object(key, info), nil
+}
+
+type memoryFile struct {
+	data   []byte
+	object StorageObject
+}
+
+/*
+Storage that keeps files in memory, meant for tests
+*/
+type MemoryStorage struct {
+	mu    sync.RWMutex
+	files map[string]memoryFile
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{files: map[string]memoryFile{}}
+}
+
+func (s *MemoryStorage) Put(ctx context.Context, key string, r io.Reader, contentType string) (StorageObject, error) {
+	if key == "" {
+		return StorageObject{}, ErrStorageInvalidKey
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return StorageObject{}, err
+	}
+
+	object := StorageObject{Key: key, Size: int64(len(data)), ContentType: contentType, ModTime: time.Now()}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.files == nil {
+		s.files = map[string]memoryFile{}
+	}
+	s.files[key] = memoryFile{data: data, object: object}
+
+	return object, nil
+}
+
+func (s *MemoryStorage) Get(ctx context.Context, key string) (io.ReadCloser, StorageObject, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	file, ok := s.files[key]
+	if !ok {
+		return nil, StorageObject{}, ErrStorageNotFound
+	}
+	return io.NopCloser(bytes.NewReader(file.data)), file.object, nil
+}
+
+func (s *MemoryStorage) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.files, key)
+	return nil
+}
+
+func (s *MemoryStorage) Rename(ctx context.Context, from string, to string) error {
+	if to == "" {
+		return ErrStorageInvalidKey
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, ok := s.files[from]
+	if !ok {
+		return ErrStorageNotFound
+	}
+	delete(s.files, from)
+	file.object.Key = to
+	s.files[to] = file
+	return nil
+}
+
+func (s *MemoryStorage) Stat(ctx context.Context, key string) (StorageObject, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	file, ok := s.files[key]
+	if !ok {
+		return StorageObject{}, ErrStorageNotFound
+	}
+	return file.object, nil
+}
@@ -0,0 +1,91 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testStorage(t *testing.T, name string, storage Storage) {
+	ctx := context.Background()
+
+	object, err := storage.Put(ctx, "docs/readme.txt", strings.NewReader("hello storage"), "text/plain")
+	if err != nil {
+		t.Fatalf("%s: Put fail! error: %s", name, err)
+	}
+	if object.Key != "docs/readme.txt" || object.Size != 13 {
+		t.Errorf("%s: unexpected stored object: %+v", name, object)
+	}
+
+	if object, err = storage.Stat(ctx, "docs/readme.txt"); err != nil || object.Size != 13 {
+		t.Errorf("%s: unexpected Stat result: %+v %v", name, object, err)
+	}
+
+	reader, _, err := storage.Get(ctx, "docs/readme.txt")
+	if err != nil {
+		t.Fatalf("%s: Get fail! error: %s", name, err)
+	}
+	content, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(content) != "hello storage" {
+		t.Errorf("%s: unexpected content: %v", name, string(content))
+	}
+
+	if err := storage.Delete(ctx, "docs/readme.txt"); err != nil {
+		t.Errorf("%s: Delete fail! error: %s", name, err)
+	}
+	if _, err := storage.Stat(ctx, "docs/readme.txt"); !errors.Is(err, ErrStorageNotFound) {
+		t.Errorf("%s: unexpected error:\ngot  %v\nwant %v", name, err, ErrStorageNotFound)
+	}
+	if _, _, err := storage.Get(ctx, "docs/readme.txt"); !errors.Is(err, ErrStorageNotFound) {
+		t.Errorf("%s: unexpected error:\ngot  %v\nwant %v", name, err, ErrStorageNotFound)
+	}
+}
+
+func TestLocalStorage(t *testing.T) {
+	root := t.TempDir()
+	testStorage(t, "LocalStorage", NewLocalStorage(root))
+
+	storage := NewLocalStorage(filepath.Join(root, "jail"))
+	for _, key := range []string{"../outside.txt", "a/../../outside.txt", "..\\outside.txt", "", "/"} {
+		if _, err := storage.Put(context.Background(), key, strings.NewReader("x"), ""); !errors.Is(err, ErrStorageInvalidKey) {
+			t.Errorf("key %q: unexpected error:\ngot  %v\nwant %v", key, err, ErrStorageInvalidKey)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "outside.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("file written outside of the storage root")
+	}
+}
+
+func TestMemoryStorage(t *testing.T) {
+	testStorage(t, "MemoryStorage", NewMemoryStorage())
+}
+
+func TestSaveUploadedFileTo(t *testing.T) {
+	storage := NewMemoryStorage()
+
+	file, err := SaveUploadedFileTo(newUploadRequest(t, map[string][]byte{"image.png": testPNG}), "file", storage, "images/1.png", UploadConfig{})
+	if err != nil {
+		t.Fatalf("SaveUploadedFileTo fail! error: %s", err)
+	}
+	if file.Path != "images/1.png" || file.ContentType != "image/png" {
+		t.Errorf("unexpected uploaded file: %+v", file)
+	}
+
+	object, err := storage.Stat(context.Background(), "images/1.png")
+	if err != nil || object.Size != int64(len(testPNG)) || object.ContentType != "image/png" {
+		t.Errorf("unexpected stored object: %+v %v", object, err)
+	}
+
+	_, err = SaveUploadedFileTo(newUploadRequest(t, map[string][]byte{"image.png": testPNG}), "file", storage, "images/2.png", UploadConfig{MaxFileSize: 10})
+	if !errors.Is(err, ErrUploadTooLarge) {
+		t.Errorf("unexpected error:\ngot  %v\nwant %v", err, ErrUploadTooLarge)
+	}
+	if _, err := storage.Stat(context.Background(), "images/2.png"); !errors.Is(err, ErrStorageNotFound) {
+		t.Errorf("rejected upload was stored")
+	}
+}
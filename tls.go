@@ -0,0 +1,190 @@
+package rest
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+/*
+Keeps the service certificate in memory and reloads it from disk when the files change
+or the process receives SIGHUP. New handshakes use the current certificate while open
+connections keep the one they were established with
+*/
+type certificateReloader struct {
+	certFile string
+	keyFile  string
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modTime  time.Time
+}
+
+func newCertificateReloader(certFile string, keyFile string) (*certificateReloader, error) {
+	c := &certificateReloader{certFile: certFile, keyFile: keyFile}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+/*
+Return the most recent modification time of the certificate and key files
+*/
+func (c *certificateReloader) lastModified() (time.Time, error) {
+	var last time.Time
+	for _, name := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return last, err
+		}
+		if info.ModTime().After(last) {
+			last = info.ModTime()
+		}
+	}
+	return last, nil
+}
+
+/*
+Load the certificate and key files, the current certificate is kept on failure
+*/
+func (c *certificateReloader) Reload() error {
+	modTime, err := c.lastModified()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.cert = &cert
+	c.modTime = modTime
+	c.mu.Unlock()
+
+	return nil
+}
+
+func (c *certificateReloader) changed() bool {
+	modTime, err := c.lastModified()
+	if err != nil {
+		return false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return !modTime.Equal(c.modTime)
+}
+
+func (c *certificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.cert, nil
+}
+
+/*
+Reload the certificate on SIGHUP or when its files change, checking every interval,
+until the context is done
+*/
+func (c *certificateReloader) watch(ctx context.Context, interval time.Duration) {
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	defer signal.Stop(hupCh)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hupCh:
+		case <-tick:
+			if !c.changed() {
+				continue
+			}
+		}
+		if err := c.Reload(); err != nil {
+			log.Printf("TLS certificate reload failed: %s\n", err)
+		}
+	}
+}
+
+/*
+Build the server TLS configuration from the service settings
+*/
+func (s *Service) tlsConfig(reloader *certificateReloader) (*tls.Config, error) {
+	cnf := &tls.Config{
+		MinVersion:     s.TLSMinVersion,
+		CipherSuites:   s.TLSCipherSuites,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if s.ClientCAFile != "" {
+		pem, err := os.ReadFile(s.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, ErrTLSClientCA
+		}
+		cnf.ClientCAs = pool
+		cnf.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return cnf, nil
+}
+
+/*
+Start listening for HTTPS requests until the process receives SIGINT or SIGTERM.
+Returns ErrGracefullShutdown when the service stops cleanly or the error that
+prevented it from starting
+*/
+func (s *Service) ListenAndServeTLS() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return s.ListenAndServeTLSContext(ctx)
+}
+
+/*
+Start listening for HTTPS requests until the context is cancelled. The certificate
+is reloaded on SIGHUP or when CertFile/KeyFile change without dropping connections
+*/
+func (s *Service) ListenAndServeTLSContext(ctx context.Context) error {
+	if s.CertFile == "" || s.KeyFile == "" {
+		return ErrTLSCertificateRequired
+	}
+
+	reloader, err := newCertificateReloader(s.CertFile, s.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	cnf, err := s.tlsConfig(reloader)
+	if err != nil {
+		return err
+	}
+	s.srv.TLSConfig = cnf
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go reloader.watch(ctx, s.CertReloadInterval)
+
+	return s.serve(ctx, func() error {
+		return s.srv.ListenAndServeTLS("", "")
+	})
+}
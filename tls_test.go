@@ -0,0 +1,190 @@
+package rest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestCertificate(t *testing.T, dir string, commonName string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestCertificateReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir, "first")
+
+	reloader, err := newCertificateReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, _ := reloader.GetCertificate(nil)
+	leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+	if leaf.Subject.CommonName != "first" {
+		t.Errorf("unexpected certificate:\ngot  %v\nwant first", leaf.Subject.CommonName)
+	}
+
+	writeTestCertificate(t, dir, "second")
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+
+	if !reloader.changed() {
+		t.Errorf("expected certificate files change to be detected")
+	}
+	if err := reloader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, _ = reloader.GetCertificate(nil)
+	leaf, _ = x509.ParseCertificate(cert.Certificate[0])
+	if leaf.Subject.CommonName != "second" {
+		t.Errorf("unexpected certificate:\ngot  %v\nwant second", leaf.Subject.CommonName)
+	}
+}
+
+func TestListenAndServeTLS(t *testing.T) {
+	port := freePort(t)
+	srv := NewService(ServiceConfig{Interface: "127.0.0.1", Port: port})
+	if err := srv.ListenAndServeTLSContext(context.Background()); !errors.Is(err, ErrTLSCertificateRequired) {
+		t.Errorf("unexpected error:\ngot  %v\nwant %v", err, ErrTLSCertificateRequired)
+	}
+
+	certFile, keyFile := writeTestCertificate(t, t.TempDir(), "127.0.0.1")
+	srv = NewService(ServiceConfig{
+		Interface: "127.0.0.1",
+		Port:      port,
+		CertFile:  certFile,
+		KeyFile:   keyFile,
+	})
+	srv.Router().HandleFunc("/tls", func(w http.ResponseWriter, r *http.Request) {
+		RespondWithJSONMessage(w, http.StatusOK, "OK")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServeTLSContext(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	pemData, _ := os.ReadFile(certFile)
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(pemData)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+
+	res, err := client.Get(fmt.Sprintf("https://127.0.0.1:%d/tls", port))
+	if err != nil {
+		t.Errorf("TLS request fail! error: %s", err)
+	} else {
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("unexpected status code: got %v want %v", res.StatusCode, http.StatusOK)
+		}
+	}
+
+	cancel()
+	if err := <-errCh; !errors.Is(err, ErrGracefullShutdown) {
+		t.Errorf("Service Listen return unexpected error:\ngot  %v\nwant %v", err, ErrGracefullShutdown)
+	}
+}
+
+func TestListenAndServeMutualTLS(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t, t.TempDir(), "127.0.0.1")
+	clientCertFile, clientKeyFile := writeTestCertificate(t, t.TempDir(), "client")
+
+	port := freePort(t)
+	invalidCA := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(invalidCA, []byte("not a certificate"), 0600)
+	srv := NewService(ServiceConfig{Port: port, CertFile: certFile, KeyFile: keyFile, ClientCAFile: invalidCA})
+	if err := srv.ListenAndServeTLSContext(context.Background()); !errors.Is(err, ErrTLSClientCA) {
+		t.Errorf("unexpected error:\ngot  %v\nwant %v", err, ErrTLSClientCA)
+	}
+
+	srv = NewService(ServiceConfig{Port: port, CertFile: certFile, KeyFile: keyFile, ClientCAFile: clientCertFile})
+	srv.Router().HandleFunc("/tls", func(w http.ResponseWriter, r *http.Request) {
+		RespondWithJSONMessage(w, http.StatusOK, "OK")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServeTLSContext(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	pemData, _ := os.ReadFile(certFile)
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(pemData)
+	clientCert, err := tls.LoadX509KeyPair(clientCertFile, clientKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, Certificates: []tls.Certificate{clientCert}}}}
+	res, err := client.Get(fmt.Sprintf("https://127.0.0.1:%d/tls", port))
+	if err != nil {
+		t.Errorf("TLS request with a client certificate fail! error: %s", err)
+	} else {
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("unexpected status code: got %v want %v", res.StatusCode, http.StatusOK)
+		}
+	}
+
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	if res, err := client.Get(fmt.Sprintf("https://127.0.0.1:%d/tls", port)); err == nil {
+		res.Body.Close()
+		t.Errorf("TLS request without a client certificate was accepted: %v", res.StatusCode)
+	}
+
+	cancel()
+	if err := <-errCh; !errors.Is(err, ErrGracefullShutdown) {
+		t.Errorf("Service Listen return unexpected error:\ngot  %v\nwant %v", err, ErrGracefullShutdown)
+	}
+}
@@ -0,0 +1,450 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const sniffLength = 512
+
+const defaultMaxFieldsSize = 10 << 20
+
+const defaultMaxParts = 1000
+
+/*
+Limits and allowed types of uploaded files, zero values disable each check
+*/
+type UploadConfig struct {
+	MaxFileSize       int64    // Maximum size in bytes of each file
+	MaxTotalSize      int64    // Maximum size in bytes of the whole request body
+	AllowedExtensions []string // Allowed file name extensions, e.g. ".jpg", case insensitive
+	AllowedMIMETypes  []string // Allowed sniffed content types, e.g. "image/png" or "image/*"
+	MaxFiles          int      // Maximum number of files processed by StreamUploads
+	MaxFieldSize      int64    // Maximum size in bytes of non file parts read by StreamUploads, default 1MB
+	MaxFieldsSize     int64    // Maximum size in bytes of the names and values of all the non file parts read by StreamUploads, default 10MB
+	MaxParts          int      // Maximum number of parts, including skipped files, read by StreamUploads, default 1000
+	HashKey           string   // When set the HMAC of the content is computed as NewHash does
+	ContentAddressed  bool     // Store files as "<key folder>/<sha256><key extension>" so identical uploads are saved once
+}
+
+/*
+Result of a saved upload
+*/
+type UploadedFile struct {
+	Path        string // Destination of the saved file, or its key in a Storage
+	Name        string // File name sent by the client
+	Size        int64  // Bytes written
+	ContentType string // Content type detected with http.DetectContentType
+	SHA256      string // Hex encoded SHA-256 digest of the content
+	HMAC        string // Hex encoded HMAC-SHA512 of the content, only when UploadConfig.HashKey is set
+	Duplicate   bool   // The content was already stored, only with UploadConfig.ContentAddressed
+}
+
+func (cnf UploadConfig) allowedExtension(name string) bool {
+	if len(cnf.AllowedExtensions) == 0 {
+		return true
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, allowed := range cnf.AllowedExtensions {
+		if strings.ToLower(allowed) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+func (cnf UploadConfig) allowedMIMEType(contentType string) bool {
+	if len(cnf.AllowedMIMETypes) == 0 {
+		return true
+	}
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(mediaType)
+	for _, allowed := range cnf.AllowedMIMETypes {
+		if allowed == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Reader that fails with ErrUploadTooLarge once more than max bytes are read and hashes
+the content while it is read
+*/
+type uploadReader struct {
+	r   io.Reader
+	n   int64
+	max int64
+	sha hash.Hash
+	mac hash.Hash
+}
+
+func (u *uploadReader) Read(p []byte) (int, error) {
+	n, err := u.r.Read(p)
+	u.n += int64(n)
+	u.sha.Write(p[:n])
+	if u.mac != nil {
+		u.mac.Write(p[:n])
+	}
+	if u.max > 0 && u.n > u.max {
+		return n, ErrUploadTooLarge
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		err = uploadError(err)
+	}
+	return n, err
+}
+
+/*
+Fill the digests of the content read so far
+*/
+func (u *uploadReader) digest(file *UploadedFile) {
+	file.SHA256 = hex.EncodeToString(u.sha.Sum(nil))
+	if u.mac != nil {
+		file.HMAC = hex.EncodeToString(u.mac.Sum(nil))
+	}
+}
+
+/*
+Check the extension and sniffed content type of an upload, returning the reader of its
+whole content limited to MaxFileSize and the detected content type
+*/
+func prepareUpload(src io.Reader, name string, cnf UploadConfig) (*uploadReader, string, error) {
+	if !cnf.allowedExtension(name) {
+		return nil, "", ErrUploadExtension
+	}
+
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(src, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, "", uploadError(err)
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	if !cnf.allowedMIMEType(contentType) {
+		return nil, contentType, ErrUploadMIMEType
+	}
+
+	reader := &uploadReader{r: io.MultiReader(bytes.NewReader(head), src), max: cnf.MaxFileSize, sha: sha256.New()}
+	if cnf.HashKey != "" {
+		reader.mac = hmac.New(sha512.New, []byte(cnf.HashKey))
+	}
+
+	return reader, contentType, nil
+}
+
+/*
+Copy an uploaded file to the writer checking its extension, sniffed content type and
+size. Returns the file without its path
+*/
+func writeUpload(w io.Writer, src io.Reader, name string, cnf UploadConfig) (UploadedFile, error) {
+	reader, contentType, err := prepareUpload(src, name, cnf)
+	if err != nil {
+		return UploadedFile{}, err
+	}
+
+	written, err := io.Copy(w, reader)
+	if err != nil {
+		return UploadedFile{}, err
+	}
+
+	file := UploadedFile{Name: name, Size: written, ContentType: contentType}
+	reader.digest(&file)
+	return file, nil
+}
+
+/*
+Store an upload under the key checking its extension, sniffed content type and size.
+When the expected size is known (not negative) a shorter upload is deleted as truncated
+*/
+func putUpload(ctx context.Context, storage Storage, key string, src io.Reader, name string, size int64, cnf UploadConfig) (UploadedFile, error) {
+	reader, contentType, err := prepareUpload(src, name, cnf)
+	if err != nil {
+		return UploadedFile{}, err
+	}
+
+	dest := key
+	if cnf.ContentAddressed {
+		dest = path.Join(path.Dir(key), ".upload-"+NewRequestID())
+	}
+
+	object, err := storage.Put(ctx, dest, reader, contentType)
+	if err != nil {
+		return UploadedFile{}, err
+	}
+	if size >= 0 && object.Size != size {
+		storage.Delete(ctx, dest)
+		return UploadedFile{}, ErrUploadTruncated
+	}
+
+	file := UploadedFile{Path: dest, Name: name, Size: object.Size, ContentType: contentType}
+	reader.digest(&file)
+
+	if cnf.ContentAddressed {
+		file.Path = path.Join(path.Dir(key), file.SHA256+strings.ToLower(path.Ext(key)))
+		if file.Duplicate, err = moveUpload(ctx, storage, dest, file.Path, contentType); err != nil {
+			storage.Delete(ctx, dest)
+			return UploadedFile{}, err
+		}
+	}
+
+	return file, nil
+}
+
+/*
+Move a stored upload to its content addressed key. When the key already exists the
+upload is a duplicate and is deleted
+*/
+func moveUpload(ctx context.Context, storage Storage, from string, to string, contentType string) (bool, error) {
+	if _, err := storage.Stat(ctx, to); err == nil {
+		return true, storage.Delete(ctx, from)
+	} else if !errors.Is(err, ErrStorageNotFound) {
+		return false, err
+	}
+
+	if renamer, ok := storage.(StorageRenamer); ok {
+		return false, renamer.Rename(ctx, from, to)
+	}
+
+	src, _, err := storage.Get(ctx, from)
+	if err != nil {
+		return false, err
+	}
+	defer src.Close()
+	if _, err := storage.Put(ctx, to, src, contentType); err != nil {
+		return false, err
+	}
+	return false, storage.Delete(ctx, from)
+}
+
+/*
+Map request body errors to their APIError, other errors are returned as they are
+*/
+func uploadError(err error) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return ErrUploadTooLarge
+	}
+	return err
+}
+
+/*
+Return the uploaded file of the form input, limiting the request body to MaxTotalSize
+before the multipart form is parsed
+*/
+func formFile(r *http.Request, formInputName string, cnf UploadConfig) (multipart.File, *multipart.FileHeader, error) {
+	if cnf.MaxTotalSize > 0 && r.MultipartForm == nil {
+		r.Body = http.MaxBytesReader(nil, r.Body, cnf.MaxTotalSize)
+	}
+
+	file, header, err := r.FormFile(formInputName)
+	if errors.Is(err, http.ErrMissingFile) {
+		return nil, nil, ErrUploadMissingFile
+	}
+	if err != nil {
+		return nil, nil, uploadError(err)
+	}
+	if cnf.MaxFileSize > 0 && header.Size > cnf.MaxFileSize {
+		file.Close()
+		return nil, nil, ErrUploadTooLarge
+	}
+
+	return file, header, nil
+}
+
+/*
+Save the file of the form input in dest applying the upload limits and allowed types
+*/
+func SaveUploadedFile(r *http.Request, formInputName string, dest string, cnf UploadConfig) (UploadedFile, error) {
+	return SaveUploadedFileTo(r, formInputName, &LocalStorage{}, dest, cnf)
+}
+
+/*
+Save the file of the form input in the storage under the key applying the upload limits
+and allowed types
+*/
+func SaveUploadedFileTo(r *http.Request, formInputName string, storage Storage, key string, cnf UploadConfig) (UploadedFile, error) {
+	src, header, err := formFile(r, formInputName, cnf)
+	if err != nil {
+		return UploadedFile{}, err
+	}
+	defer src.Close()
+
+	return putUpload(r.Context(), storage, key, src, header.Filename, header.Size, cnf)
+}
+
+/*
+Save the file of the form input as a temporal file in destFolder applying the upload
+limits and allowed types. Temporal files names include an UID prefix and are never
+content addressed
+*/
+func SaveTmpUploadedFile(r *http.Request, formInputName string, destFolder string, cnf UploadConfig) (UploadedFile, error) {
+	src, header, err := formFile(r, formInputName, cnf)
+	if err != nil {
+		return UploadedFile{}, err
+	}
+	defer src.Close()
+
+	dst, err := os.CreateTemp(destFolder, "*."+FixFileName(header.Filename))
+	if err != nil {
+		return UploadedFile{}, err
+	}
+
+	file, err := writeUpload(dst, src, header.Filename, cnf)
+	if err == nil && file.Size != header.Size {
+		err = ErrUploadTruncated
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst.Name())
+		return UploadedFile{}, err
+	}
+
+	file.Path = dst.Name()
+	return file, nil
+}
+
+/*
+Part of a multipart request received by StreamUploads
+*/
+type UploadPart struct {
+	FormName string
+	FileName string
+	Header   textproto.MIMEHeader
+}
+
+/*
+Files and fields processed by StreamUploads, in the order they were received
+*/
+type UploadStream struct {
+	Files  []UploadedFile
+	Fields url.Values
+}
+
+/*
+Callback returning the destination path (or storage key) of a file part, the fields
+received before the part are available. An empty path skips the part
+*/
+type UploadDestination func(part UploadPart, fields url.Values) (string, error)
+
+/*
+Process a multipart request part by part with r.MultipartReader, without buffering the
+form in memory or temporal files. Fields are collected and files are written to the
+path returned by the destination callback. On failure the files written by the call
+are removed
+*/
+func StreamUploads(r *http.Request, cnf UploadConfig, destination UploadDestination) (UploadStream, error) {
+	return StreamUploadsTo(r, cnf, &LocalStorage{}, destination)
+}
+
+/*
+Same as StreamUploads storing the files in the storage under the keys returned by the
+destination callback
+*/
+func StreamUploadsTo(r *http.Request, cnf UploadConfig, storage Storage, destination UploadDestination) (UploadStream, error) {
+	if cnf.MaxFieldSize <= 0 {
+		cnf.MaxFieldSize = defaultMaxBodySize
+	}
+	if cnf.MaxFieldsSize <= 0 {
+		cnf.MaxFieldsSize = defaultMaxFieldsSize
+	}
+	if cnf.MaxParts <= 0 {
+		cnf.MaxParts = defaultMaxParts
+	}
+	if cnf.MaxTotalSize > 0 {
+		r.Body = http.MaxBytesReader(nil, r.Body, cnf.MaxTotalSize)
+	}
+
+	result := UploadStream{Fields: url.Values{}}
+	fieldsSize := int64(0)
+	parts := 0
+	fail := func(err error) (UploadStream, error) {
+		for _, file := range result.Files {
+			if !file.Duplicate {
+				storage.Delete(r.Context(), file.Path)
+			}
+		}
+		return UploadStream{}, uploadError(err)
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return UploadStream{}, NewAPIError(http.StatusBadRequest, "invalid_form", "invalid multipart form").WithCause(err)
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fail(err)
+		}
+		if parts++; parts > cnf.MaxParts {
+			part.Close()
+			return fail(ErrUploadTooManyParts)
+		}
+
+		if part.FileName() == "" {
+			value, err := io.ReadAll(io.LimitReader(part, cnf.MaxFieldSize+1))
+			part.Close()
+			if err != nil {
+				return fail(err)
+			}
+			if int64(len(value)) > cnf.MaxFieldSize {
+				return fail(ErrUploadFieldTooLarge)
+			}
+			if fieldsSize += int64(len(part.FormName()) + len(value)); fieldsSize > cnf.MaxFieldsSize {
+				return fail(ErrUploadFieldsTooLarge)
+			}
+			result.Fields.Add(part.FormName(), string(value))
+			continue
+		}
+
+		if cnf.MaxFiles > 0 && len(result.Files) >= cnf.MaxFiles {
+			part.Close()
+			return fail(ErrUploadTooManyFiles)
+		}
+
+		info := UploadPart{FormName: part.FormName(), FileName: part.FileName(), Header: part.Header}
+		dest, err := destination(info, result.Fields)
+		if err != nil {
+			part.Close()
+			return fail(err)
+		}
+		if dest == "" {
+			part.Close()
+			continue
+		}
+
+		file, err := putUpload(r.Context(), storage, dest, part, info.FileName, -1, cnf)
+		part.Close()
+		if err != nil {
+			return fail(err)
+		}
+		result.Files = append(result.Files, file)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,232 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var testPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00\x1f\x15\xc4\x89")
+
+func newUploadRequest(t *testing.T, files map[string][]byte) *http.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for name, content := range files {
+		part, err := writer.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(content)
+	}
+	writer.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/upload", body)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+	return r
+}
+
+func TestSaveUploadedFile(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "image.png")
+
+	cnf := UploadConfig{
+		MaxFileSize:       1024,
+		AllowedExtensions: []string{".PNG", ".jpg"},
+		AllowedMIMETypes:  []string{"image/*"},
+	}
+
+	file, err := SaveUploadedFile(newUploadRequest(t, map[string][]byte{"image.png": testPNG}), "file", dest, cnf)
+	if err != nil {
+		t.Fatalf("SaveUploadedFile fail! error: %s", err)
+	}
+	if file.Path != dest || file.Name != "image.png" || file.Size != int64(len(testPNG)) || file.ContentType != "image/png" {
+		t.Errorf("unexpected uploaded file: %+v", file)
+	}
+
+	tests := []struct {
+		name    string
+		content []byte
+		cnf     UploadConfig
+		want    error
+	}{
+		{"image.gif", testPNG, cnf, ErrUploadExtension},
+		{"image.png", []byte("plain text content"), cnf, ErrUploadMIMEType},
+		{"image.png", append(testPNG, make([]byte, 2048)...), cnf, ErrUploadTooLarge},
+		{"image.png", append(testPNG, make([]byte, 2048)...), UploadConfig{MaxTotalSize: 512}, ErrUploadTooLarge},
+	}
+
+	for i, test := range tests {
+		os.Remove(dest)
+		_, err := SaveUploadedFile(newUploadRequest(t, map[string][]byte{test.name: test.content}), "file", dest, test.cnf)
+		if !errors.Is(err, test.want) {
+			t.Errorf("case %d: unexpected error:\ngot  %v\nwant %v", i, err, test.want)
+		}
+		if _, err := os.Stat(dest); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("case %d: rejected upload was not removed", i)
+		}
+	}
+
+	_, err = SaveUploadedFile(newUploadRequest(t, map[string][]byte{}), "file", dest, cnf)
+	if !errors.Is(err, ErrUploadMissingFile) {
+		t.Errorf("unexpected error:\ngot  %v\nwant %v", err, ErrUploadMissingFile)
+	}
+}
+
+func TestSaveTmpUploadedFile(t *testing.T) {
+	dir := t.TempDir()
+
+	file, err := SaveTmpUploadedFile(newUploadRequest(t, map[string][]byte{"my image.png": testPNG}), "file", dir, UploadConfig{})
+	if err != nil {
+		t.Fatalf("SaveTmpUploadedFile fail! error: %s", err)
+	}
+	if filepath.Dir(file.Path) != dir || filepath.Ext(file.Path) != ".png" {
+		t.Errorf("unexpected temporal file path: %v", file.Path)
+	}
+
+	content, _ := os.ReadFile(file.Path)
+	if !bytes.Equal(content, testPNG) {
+		t.Errorf("unexpected temporal file content")
+	}
+}
+
+func TestStreamUploads(t *testing.T) {
+	dir := t.TempDir()
+
+	newRequest := func() *http.Request {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		writer.WriteField("folder", "avatars")
+		part, _ := writer.CreateFormFile("first", "a.png")
+		part.Write(testPNG)
+		writer.WriteField("comment", "second file")
+		part, _ = writer.CreateFormFile("second", "b.txt")
+		part.Write([]byte("some text"))
+		part, _ = writer.CreateFormFile("skipped", "c.txt")
+		part.Write([]byte("skipped file"))
+		writer.Close()
+
+		r := httptest.NewRequest(http.MethodPost, "/upload", body)
+		r.Header.Set("Content-Type", writer.FormDataContentType())
+		return r
+	}
+
+	folders := []string{}
+	destination := func(part UploadPart, fields url.Values) (string, error) {
+		folders = append(folders, fields.Get("folder"))
+		if part.FormName == "skipped" {
+			return "", nil
+		}
+		return filepath.Join(dir, part.FileName), nil
+	}
+
+	stream, err := StreamUploads(newRequest(), UploadConfig{}, destination)
+	if err != nil {
+		t.Fatalf("StreamUploads fail! error: %s", err)
+	}
+	if len(stream.Files) != 2 || stream.Files[0].Name != "a.png" || stream.Files[1].Name != "b.txt" {
+		t.Errorf("unexpected uploaded files: %+v", stream.Files)
+	}
+	if stream.Fields.Get("folder") != "avatars" || stream.Fields.Get("comment") != "second file" {
+		t.Errorf("unexpected form fields: %v", stream.Fields)
+	}
+	if !reflect.DeepEqual(folders, []string{"avatars", "avatars", "avatars"}) {
+		t.Errorf("unexpected fields received by the destination callback: %v", folders)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "b.txt")); string(content) != "some text" {
+		t.Errorf("unexpected file content: %v", string(content))
+	}
+
+	os.Remove(filepath.Join(dir, "a.png"))
+	os.Remove(filepath.Join(dir, "b.txt"))
+
+	_, err = StreamUploads(newRequest(), UploadConfig{AllowedMIMETypes: []string{"image/png"}}, destination)
+	if !errors.Is(err, ErrUploadMIMEType) {
+		t.Errorf("unexpected error:\ngot  %v\nwant %v", err, ErrUploadMIMEType)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("files of a failed stream were not removed: %v", entries)
+	}
+
+	_, err = StreamUploads(newRequest(), UploadConfig{MaxFiles: 1}, destination)
+	if !errors.Is(err, ErrUploadTooManyFiles) {
+		t.Errorf("unexpected error:\ngot  %v\nwant %v", err, ErrUploadTooManyFiles)
+	}
+
+	_, err = StreamUploads(newRequest(), UploadConfig{MaxFieldsSize: 20}, destination)
+	if !errors.Is(err, ErrUploadFieldsTooLarge) {
+		t.Errorf("unexpected error:\ngot  %v\nwant %v", err, ErrUploadFieldsTooLarge)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("files of a failed stream were not removed: %v", entries)
+	}
+
+	_, err = StreamUploads(newRequest(), UploadConfig{MaxParts: 4}, destination)
+	if !errors.Is(err, ErrUploadTooManyParts) {
+		t.Errorf("unexpected error:\ngot  %v\nwant %v", err, ErrUploadTooManyParts)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("files of a failed stream were not removed: %v", entries)
+	}
+}
+
+func TestUploadHashing(t *testing.T) {
+	sum := sha256.Sum256(testPNG)
+	wantSHA := hex.EncodeToString(sum[:])
+	wantHMAC := NewHash(string(testPNG), "secret")
+
+	cnf := UploadConfig{HashKey: "secret"}
+	file, err := SaveUploadedFile(newUploadRequest(t, map[string][]byte{"image.png": testPNG}), "file", filepath.Join(t.TempDir(), "image.png"), cnf)
+	if err != nil {
+		t.Fatalf("SaveUploadedFile fail! error: %s", err)
+	}
+	if file.SHA256 != wantSHA || file.HMAC != wantHMAC {
+		t.Errorf("unexpected digests:\ngot  %s %s\nwant %s %s", file.SHA256, file.HMAC, wantSHA, wantHMAC)
+	}
+
+	file, err = SaveTmpUploadedFile(newUploadRequest(t, map[string][]byte{"image.png": testPNG}), "file", t.TempDir(), UploadConfig{})
+	if err != nil {
+		t.Fatalf("SaveTmpUploadedFile fail! error: %s", err)
+	}
+	if file.SHA256 != wantSHA || file.HMAC != "" {
+		t.Errorf("unexpected digests: %s %s", file.SHA256, file.HMAC)
+	}
+}
+
+func TestUploadContentAddressed(t *testing.T) {
+	sum := sha256.Sum256(testPNG)
+	want := "images/" + hex.EncodeToString(sum[:]) + ".png"
+	cnf := UploadConfig{ContentAddressed: true}
+
+	for name, storage := range map[string]Storage{"LocalStorage": NewLocalStorage(t.TempDir()), "MemoryStorage": NewMemoryStorage()} {
+		first, err := SaveUploadedFileTo(newUploadRequest(t, map[string][]byte{"a.png": testPNG}), "file", storage, "images/a.PNG", cnf)
+		if err != nil {
+			t.Fatalf("%s: SaveUploadedFileTo fail! error: %s", name, err)
+		}
+		second, err := SaveUploadedFileTo(newUploadRequest(t, map[string][]byte{"b.png": testPNG}), "file", storage, "images/b.png", cnf)
+		if err != nil {
+			t.Fatalf("%s: SaveUploadedFileTo fail! error: %s", name, err)
+		}
+
+		if first.Path != want || first.Duplicate || second.Path != want || !second.Duplicate {
+			t.Errorf("%s: unexpected uploaded files:\n%+v\n%+v", name, first, second)
+		}
+		if object, err := storage.Stat(context.Background(), want); err != nil || object.Size != int64(len(testPNG)) {
+			t.Errorf("%s: unexpected stored object: %+v %v", name, object, err)
+		}
+		for _, key := range []string{"images/a.PNG", "images/b.png"} {
+			if _, err := storage.Stat(context.Background(), key); !errors.Is(err, ErrStorageNotFound) {
+				t.Errorf("%s: unexpected file stored under %s", name, key)
+			}
+		}
+	}
+}
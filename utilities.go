@@ -3,15 +3,24 @@ package rest
 import (
 	"crypto/hmac"
 	"crypto/sha512"
+	"encoding"
+	"errors"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
-	"os"
+	"net/url"
+	"path/filepath"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/text/unicode/norm"
 )
 
 /*
@@ -23,80 +32,581 @@ func NewHash(payload string, secretKey string) string {
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
 
+const maxFormSliceLength = 1000
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+var timeType = reflect.TypeOf(time.Time{})
+var durationType = reflect.TypeOf(time.Duration(0))
+
+var formScalars sync.Map
+
 /*
-Reads the form information of a request and assigns the form data to its corresponding structure fields
+Name and options of a "form" struct tag, e.g. `form:"created,layout=2006-01-02"`,
+`form:"page,default=1"` or `form:"email,required"`
 */
-func FormToStruct(r *http.Request, model interface{}) error {
+type formTag struct {
+	name       string
+	layout     string
+	def        string
+	hasDefault bool
+	required   bool
+}
+
+func parseFormTag(tag string) formTag {
+	name, options, _ := strings.Cut(tag, ",")
+	ft := formTag{name: strings.TrimSpace(name)}
+	for _, option := range strings.Split(options, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(option), "=")
+		switch key {
+		case "layout":
+			ft.layout = value
+		case "default":
+			ft.def = value
+			ft.hasDefault = true
+		case "required":
+			ft.required = true
+		}
+	}
+	return ft
+}
+
+/*
+Return true if a single form value is assigned to the type: basic kinds, time.Time,
+time.Duration and encoding.TextUnmarshaler implementations
+*/
+func isFormScalar(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+
+	if scalar, ok := formScalars.Load(t); ok {
+		return scalar.(bool)
+	}
+	scalar := t == timeType || reflect.PointerTo(t).Implements(textUnmarshalerType)
+	formScalars.Store(t, scalar)
+	return scalar
+}
+
+/*
+Parse a form value into the field, checking the range of the field bit size. Besides
+basic kinds time.Time (using the layout, RFC 3339 by default), time.Duration and
+encoding.TextUnmarshaler fields are supported
+*/
+func setFieldValue(field reflect.Value, value string, layout string) error {
+	switch field.Type() {
+	case timeType:
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		val, err := time.Parse(layout, value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(val))
+		return nil
+	case durationType:
+		val, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(val))
+		return nil
+	}
+
+	if field.CanAddr() && field.Addr().Type().Implements(textUnmarshalerType) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+
+	switch field.Kind() {
+	case reflect.Bool:
+		val, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(val)
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Float32, reflect.Float64:
+		val, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(val)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		val, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(val)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		val, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(val)
+	}
+	return nil
+}
+
+/*
+Return the field error detail for a form value that can not be parsed
+*/
+func formFieldError(name string, value string, err error) FieldError {
+	code := "invalid"
+	message := fmt.Sprintf("%s: invalid value %q", name, value)
+	if errors.Is(err, strconv.ErrRange) {
+		code = "out_of_range"
+		message = fmt.Sprintf("%s: value %q is out of range", name, value)
+	}
+	return FieldError{Field: name, Code: code, Message: message, Value: value}
+}
+
+/*
+Return the form key using dots for nested fields, "items[0][name]" and "items[0].name"
+become "items.0.name" and "tags[]" becomes "tags"
+*/
+func normalizeFormKey(key string) string {
+	if !strings.ContainsAny(key, "[]") {
+		return key
+	}
+	key = strings.ReplaceAll(key, "][", ".")
+	key = strings.ReplaceAll(key, "[", ".")
+	key = strings.ReplaceAll(key, "]", "")
+	return strings.TrimSuffix(key, ".")
+}
+
+func formKeysNeedNormalization(values url.Values) bool {
+	for key := range values {
+		if strings.ContainsAny(key, "[]") {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Assign the values of a request source (form, query, path or header) to the structure
+fields with the matching tag, keeping the failing fields and the keys that were used
+*/
+type formBinder struct {
+	tag     string
+	values  url.Values
+	used    map[string]bool
+	details []FieldError
+}
+
+func newFormBinder(tag string, values url.Values) *formBinder {
+	if tag != "header" && !formKeysNeedNormalization(values) {
+		return &formBinder{tag: tag, values: values, used: map[string]bool{}}
+	}
+
+	normalized := make(url.Values, len(values))
+	for key, vals := range values {
+		name := normalizeFormKey(key)
+		if tag == "header" {
+			name = http.CanonicalHeaderKey(key)
+		}
+		normalized[name] = append(normalized[name], vals...)
+	}
+	return &formBinder{tag: tag, values: normalized, used: map[string]bool{}}
+}
+
+/*
+Return true if the form has a value for the key or for any of its nested keys
+*/
+func (b *formBinder) present(key string, t reflect.Type) bool {
+	if isFormScalar(t) {
+		values := b.values[key]
+		return len(values) > 0 && values[0] != ""
+	}
+	if len(b.values[key]) > 0 {
+		return true
+	}
+	for k := range b.values {
+		if strings.HasPrefix(k, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Return the values of the key, or the tag default value when the key is absent
+*/
+func (b *formBinder) lookup(key string, ft formTag) []string {
+	values := b.values[key]
+	if (len(values) == 0 || values[0] == "") && ft.hasDefault {
+		return []string{ft.def}
+	}
+	return values
+}
+
+/*
+Return the number of elements of an indexed slice key, e.g. 2 for "items.0.name" and "items.1.name"
+*/
+func (b *formBinder) indexedLength(key string) int {
+	length := 0
+	for k := range b.values {
+		if !strings.HasPrefix(k, key+".") {
+			continue
+		}
+		index, _, _ := strings.Cut(k[len(key)+1:], ".")
+		if i, err := strconv.Atoi(index); err == nil && i >= 0 && i+1 > length {
+			length = i + 1
+		}
+	}
+	return length
+}
+
+/*
+Binding instructions of a structure field for a tag, computed once per type
+*/
+type formField struct {
+	index    int
+	embedded bool
+	ft       formTag
+}
+
+type formPlanKey struct {
+	t   reflect.Type
+	tag string
+}
+
+var formPlans sync.Map
+
+/*
+Return the fields of the structure type bound with the tag. Plans are cached so
+struct fields and tags are only walked and parsed the first time a type is used
+*/
+func formPlan(t reflect.Type, tag string) []formField {
+	key := formPlanKey{t: t, tag: tag}
+	if plan, ok := formPlans.Load(key); ok {
+		return plan.([]formField)
+	}
+
+	plan := []formField{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := field.Tag.Get(tag)
+		if value == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+
+		if value == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if field.Anonymous && embedded.Kind() == reflect.Struct {
+				plan = append(plan, formField{index: i, embedded: true})
+			}
+			continue
+		}
+
+		ft := parseFormTag(value)
+		if tag == "header" {
+			ft.name = http.CanonicalHeaderKey(ft.name)
+		}
+		plan = append(plan, formField{index: i, ft: ft})
+	}
+
+	cached, _ := formPlans.LoadOrStore(key, plan)
+	return cached.([]formField)
+}
+
+func (b *formBinder) bindStruct(v reflect.Value, prefix string) {
+	for _, field := range formPlan(v.Type(), b.tag) {
+		if !field.embedded {
+			b.bindValue(v.Field(field.index), prefix+field.ft.name, field.ft)
+			continue
+		}
+
+		embedded := v.Field(field.index)
+		if embedded.Kind() == reflect.Ptr {
+			if embedded.IsNil() {
+				if !embedded.CanSet() {
+					continue
+				}
+				embedded.Set(reflect.New(embedded.Type().Elem()))
+			}
+			embedded = embedded.Elem()
+		}
+		b.bindStruct(embedded, prefix)
+	}
+}
+
+func (b *formBinder) bindValue(field reflect.Value, key string, ft formTag) {
+	t := field.Type()
+
+	if ft.required {
+		base := t
+		if base.Kind() == reflect.Ptr {
+			base = base.Elem()
+		}
+		if !b.present(key, base) {
+			b.used[key] = true
+			b.details = append(b.details, FieldError{
+				Field: key, Code: "required", Message: fmt.Sprintf("%s: is required", key),
+			})
+			return
+		}
+	}
+
+	if t.Kind() == reflect.Ptr {
+		b.used[key] = true
+		if !b.present(key, t.Elem()) && !(ft.hasDefault && isFormScalar(t.Elem())) {
+			return
+		}
+		if field.IsNil() {
+			field.Set(reflect.New(t.Elem()))
+		}
+		b.bindValue(field.Elem(), key, ft)
+		return
+	}
+
+	if isFormScalar(t) {
+		b.used[key] = true
+		values := b.lookup(key, ft)
+		if len(values) > 0 && values[0] != "" {
+			if err := setFieldValue(field, values[0], ft.layout); err != nil {
+				b.details = append(b.details, formFieldError(key, values[0], err))
+			}
+		}
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		b.bindStruct(field, key+".")
+	case reflect.Slice:
+		elem := t.Elem()
+		if elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+		if isFormScalar(elem) {
+			b.bindScalarSlice(field, key, ft)
+		} else {
+			b.bindIndexedSlice(field, key, ft)
+		}
+	}
+}
+
+/*
+Assign repeated keys, e.g. "tags=a&tags=b", to a slice
+*/
+func (b *formBinder) bindScalarSlice(field reflect.Value, key string, ft formTag) {
+	b.used[key] = true
+	values := b.lookup(key, ft)
+	if len(values) == 0 {
+		return
+	}
+	if len(values) > maxFormSliceLength {
+		b.details = append(b.details, FieldError{
+			Field: key, Code: "out_of_range", Message: fmt.Sprintf("%s: too many values", key),
+		})
+		return
+	}
+
+	slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+	for i, value := range values {
+		item := slice.Index(i)
+		if item.Kind() == reflect.Ptr {
+			item.Set(reflect.New(item.Type().Elem()))
+			item = item.Elem()
+		}
+		if err := setFieldValue(item, value, ft.layout); err != nil {
+			b.details = append(b.details, formFieldError(fmt.Sprintf("%s.%d", key, i), value, err))
+		}
+	}
+	field.Set(slice)
+}
+
+/*
+Assign indexed keys, e.g. "items[0].name", to a slice of structures
+*/
+func (b *formBinder) bindIndexedSlice(field reflect.Value, key string, ft formTag) {
+	length := b.indexedLength(key)
+	if length == 0 {
+		return
+	}
+	if length > maxFormSliceLength {
+		b.details = append(b.details, FieldError{
+			Field: key, Code: "out_of_range", Message: fmt.Sprintf("%s: too many values", key),
+		})
+		return
+	}
+
+	slice := reflect.MakeSlice(field.Type(), length, length)
+	for i := 0; i < length; i++ {
+		b.bindValue(slice.Index(i), fmt.Sprintf("%s.%d", key, i), formTag{layout: ft.layout})
+	}
+	field.Set(slice)
+}
+
+func formToStruct(r *http.Request, model interface{}, strict bool) error {
 	v := reflect.ValueOf(model)
 
-	if v.Kind() != reflect.Ptr {
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
 		return ErrFormToStructPtrExpected
 	}
 
-	for i := 0; i < v.Elem().NumField(); i++ {
-		tag := v.Elem().Type().Field(i).Tag.Get("form")
-		if tag != "" {
-			value := r.FormValue(tag)
-			if value != "" {
-				switch v.Elem().Type().Field(i).Type.Kind() {
-				case reflect.Bool:
-					val, _ := strconv.ParseBool(value)
-					v.Elem().Field(i).SetBool(val)
-				case reflect.String:
-					v.Elem().Field(i).SetString(value)
-				case reflect.Float32, reflect.Float64:
-					val, _ := strconv.ParseFloat(value, 64)
-					v.Elem().Field(i).SetFloat(val)
-				case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-					val, _ := strconv.Atoi(value)
-					v.Elem().Field(i).SetInt(int64(val))
-				case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-					val, _ := strconv.Atoi(value)
-					v.Elem().Field(i).SetUint(uint64(val))
-				}
+	if err := r.ParseMultipartForm(32 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return NewAPIError(http.StatusBadRequest, "invalid_form", "invalid form data").WithCause(err)
+	}
+
+	b := newFormBinder("form", r.Form)
+	b.bindStruct(v.Elem(), "")
+
+	if strict {
+		keys := []string{}
+		for key := range b.values {
+			if !b.used[key] {
+				keys = append(keys, key)
 			}
 		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			b.details = append(b.details, FieldError{
+				Field: key, Code: "unknown", Message: fmt.Sprintf("%s: unknown form field", key), Value: b.values.Get(key),
+			})
+		}
+	}
+
+	if len(b.details) > 0 {
+		return NewAPIError(http.StatusBadRequest, "invalid_form", "invalid form data").WithDetails(b.details...)
 	}
 
 	return nil
 }
 
-func FixFileName(name string) string {
-	r := regexp.MustCompile("[^aA-zZ0-9ñÑáÁéÉíÍóÓúÚ._()]+")
+/*
+Reads the form information of a request and assigns the form data to its corresponding structure fields.
+Values that can not be parsed are reported as an APIError with a detail per failing field
+*/
+func FormToStruct(r *http.Request, model interface{}) error {
+	return formToStruct(r, model, false)
+}
 
-	return r.ReplaceAllString(name, "-")
+/*
+Same as FormToStruct but form keys not declared in the structure "form" tags are reported as errors
+*/
+func FormToStructStrict(r *http.Request, model interface{}) error {
+	return formToStruct(r, model, true)
 }
 
-func SaveFileFromRequest(r *http.Request, formInputName string, dest string) error {
-	var err error
-	file, _, err := r.FormFile(formInputName)
-	if err == nil {
-		f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE, 0666)
-		if err == nil {
-			_, _ = io.Copy(f, file)
-		}
-		defer f.Close()
+/*
+Assign the request inputs to the structure fields by tag: "form" (body and query form
+values), "query" (URL query only), "path" (mux route variables) and "header". Tag
+options are the same of FormToStruct and failures are reported in a single APIError
+*/
+func BindRequest(r *http.Request, model interface{}) error {
+	v := reflect.ValueOf(model)
+
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return ErrFormToStructPtrExpected
 	}
-	defer file.Close()
 
-	return err
+	if err := r.ParseMultipartForm(32 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return NewAPIError(http.StatusBadRequest, "invalid_request", "invalid request data").WithCause(err)
+	}
+
+	path := url.Values{}
+	for key, value := range mux.Vars(r) {
+		path.Set(key, value)
+	}
+
+	details := []FieldError{}
+	for _, b := range []*formBinder{
+		newFormBinder("form", r.Form),
+		newFormBinder("query", r.URL.Query()),
+		newFormBinder("path", path),
+		newFormBinder("header", url.Values(r.Header)),
+	} {
+		b.bindStruct(v.Elem(), "")
+		details = append(details, b.details...)
+	}
+
+	if len(details) > 0 {
+		return NewAPIError(http.StatusBadRequest, "invalid_request", "invalid request data").WithDetails(details...)
+	}
+
+	return nil
 }
 
-func SaveTmpFileFromRequest(r *http.Request, formInputName string, destFolder string) (string, error) {
-	var result string
-	var err error
-	input, handler, err := r.FormFile(formInputName)
-	if err == nil {
-		file, err := os.CreateTemp(destFolder, "*."+FixFileName(handler.Filename))
-		if err == nil {
-			_, _ = io.Copy(file, input)
-			result = file.Name()
+const maxFileNameLength = 255
+
+var fileNameInvalid = regexp.MustCompile(`[^\p{L}\p{M}\p{Nd}._()-]+`)
+var fileNameDots = regexp.MustCompile(`\.{2,}`)
+var fileNameDashes = regexp.MustCompile(`-{2,}`)
+
+/*
+Base names reserved by Windows, with or without extension
+*/
+var reservedFileNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+/*
+Return a valid file name representation for the OS file system. The name is NFC
+normalized, characters other than Unicode letters, digits, ".", "_", "(", ")" and "-"
+are replaced with "-", dash and dot sequences are collapsed and leading or trailing dots
+and dashes removed so the name can not be "..", hidden or taken as a command option.
+Dashes around the extension dot are removed so the extension is kept. Reserved
+device names are prefixed with "_" and the name is truncated to 255 bytes keeping its
+extension
+*/
+func FixFileName(name string) string {
+	name = norm.NFC.String(name)
+	name = fileNameInvalid.ReplaceAllString(name, "-")
+	name = fileNameDashes.ReplaceAllString(name, "-")
+	name = fileNameDots.ReplaceAllString(name, ".")
+	name = strings.Trim(name, ".-")
+	if name == "" {
+		return "file"
+	}
+	if ext := filepath.Ext(name); ext != "" {
+		name = strings.TrimRight(strings.TrimSuffix(name, ext), "-") + "." + strings.TrimLeft(ext[1:], "-")
+	}
+
+	base, _, _ := strings.Cut(name, ".")
+	if reservedFileNames[strings.ToUpper(base)] {
+		name = "_" + name
+	}
+
+	if len(name) > maxFileNameLength {
+		ext := filepath.Ext(name)
+		if len(ext) > maxFileNameLength/2 {
+			ext = ""
+		}
+		base := name[:maxFileNameLength-len(ext)]
+		for !utf8.ValidString(base) {
+			base = base[:len(base)-1]
 		}
-		defer file.Close()
+		name = base + ext
 	}
-	defer input.Close()
 
-	return result, err
+	return name
+}
+
+/*
+Save the file of the form input in dest. The destination file is removed when the copy fails
+*/
+func SaveFileFromRequest(r *http.Request, formInputName string, dest string) error {
+	_, err := SaveUploadedFile(r, formInputName, dest, UploadConfig{})
+	return err
+}
+
+/*
+Save the file of the form input as a temporal file in destFolder, returning its path
+*/
+func SaveTmpFileFromRequest(r *http.Request, formInputName string, destFolder string) (string, error) {
+	file, err := SaveTmpUploadedFile(r, formInputName, destFolder, UploadConfig{})
+	return file.Path, err
 }
 
 func ParseAuthorizationHeader(r *http.Request) string {
@@ -119,8 +629,8 @@ func xffIP(r *http.Request) string {
 	if len(xff) != 0 {
 		addrs := strings.Split(xff, ",")
 		lastFwd := addrs[len(addrs)-1]
-		if ip := net.ParseIP(lastFwd); ip != nil {
-			remoteIP = ip.String()
+		if addr, ok := parseHostAddr(lastFwd); ok {
+			remoteIP = addr.Unmap().String()
 		}
 	}
 	return remoteIP
@@ -128,26 +638,32 @@ func xffIP(r *http.Request) string {
 
 func xriIP(r *http.Request) string {
 	var remoteIP string
-	var xri string = r.Header.Get("X-Real-Ip")
 
-	if ip := net.ParseIP(xri); ip != nil {
-		remoteIP = ip.String()
+	if addr, ok := parseHostAddr(r.Header.Get("X-Real-Ip")); ok {
+		remoteIP = addr.Unmap().String()
 	}
 
 	return remoteIP
 }
 
+/*
+Return the IP of RemoteAddr, which may be an IPv6 address in brackets with a zone,
+e.g. "[fe80::1%eth0]:8080". Returns empty when RemoteAddr is not an IP address
+*/
 func remoteAddr(r *http.Request) string {
-	ip := ""
-	var parts []string = strings.Split(r.RemoteAddr, ":")
-
-	if len(parts) == 2 {
-		ip = parts[0]
+	addr, ok := parseHostAddr(r.RemoteAddr)
+	if !ok {
+		return ""
 	}
 
-	return ip
+	return addr.Unmap().String()
 }
 
+/*
+Return the client IP taken from X-Real-Ip, the last X-Forwarded-For hop or RemoteAddr.
+The headers are trusted from anyone, behind reverse proxies use a ClientIPResolver
+configured with the trusted proxies instead
+*/
 func GetRealIPAddr(r *http.Request) string {
 	var remoteIP string = remoteAddr(r)
 	var xff string = xffIP(r)
@@ -157,7 +673,7 @@ func GetRealIPAddr(r *http.Request) string {
 		remoteIP = xff
 	}
 	if xri != "" {
-		remoteIP = xff
+		remoteIP = xri
 	}
 
 	return remoteIP
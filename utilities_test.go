@@ -9,21 +9,61 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
 )
 
 func TestFixFileName(t *testing.T) {
 
 	fName := "Esta Es Una con (ñ,Ñ,á,Á,â+|@/) Prueba.jpg"
 	fixed := FixFileName(fName)
-	expected := "Esta-Es-Una-con-(ñ-Ñ-á-Á-)-Prueba.jpg"
+	expected := "Esta-Es-Una-con-(ñ-Ñ-á-Á-â-)-Prueba.jpg"
 
 	if expected != fixed {
 		t.Errorf("function return unexpected value: \n\t got %v\n\twant %v", fixed, expected)
 	}
 }
 
+func TestFixFileNameHardening(t *testing.T) {
+	long := strings.Repeat("á", 200) + ".pdf"
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"a[b]^c`d\\e.txt", "a-b-c-d-e.txt"},
+		{"..", "file"},
+		{"../../etc/passwd", "etc-passwd"},
+		{".hidden", "hidden"},
+		{"report..pdf", "report.pdf"},
+		{"CON", "_CON"},
+		{"nul.txt", "_nul.txt"},
+		{"console.txt", "console.txt"},
+		{"cafe\u0301.txt", "café.txt"},
+		{"photo .jpg", "photo.jpg"},
+		{"report (final) .pdf", "report-(final).pdf"},
+		{"a - b.- txt", "a-b.txt"},
+		{"Straße.pdf", "Straße.pdf"},
+		{"отчёт 2024.pdf", "отчёт-2024.pdf"},
+		{"", "file"},
+		{long, strings.Repeat("á", 125) + ".pdf"},
+	}
+
+	for _, test := range tests {
+		if fixed := FixFileName(test.name); fixed != test.expected {
+			t.Errorf("function return unexpected value for %q: \n\t got %v\n\twant %v", test.name, fixed, test.expected)
+		}
+		if fixed := FixFileName(test.name); len(fixed) > maxFileNameLength || !utf8.ValidString(fixed) {
+			t.Errorf("invalid file name for %q: %q", test.name, fixed)
+		}
+	}
+}
+
 func TestSaveFileFromRequest(t *testing.T) {
 	pr, pw := io.Pipe()
 	writer := multipart.NewWriter(pw)
@@ -195,11 +235,44 @@ func TestGetRealIPAddr(t *testing.T) {
 	req.Header.Add("X-Forwarded-For", "192.168.0.1")
 	req.Header.Add("X-Real-Ip", "192.168.0.2")
 
+	if ip := GetRealIPAddr(req); ip != "192.168.0.2" {
+		t.Errorf("Unexpected IP value: \n\t got %v\n\twant %v", ip, "192.168.0.2")
+	}
+
+	req.Header.Del("X-Real-Ip")
 	if ip := GetRealIPAddr(req); ip != "192.168.0.1" {
 		t.Errorf("Unexpected IP value: \n\t got %v\n\twant %v", ip, "192.168.0.1")
 	}
 }
 
+func TestGetRealIPAddrIPv6(t *testing.T) {
+	tests := []struct {
+		remoteAddr string
+		xff        string
+		expected   string
+	}{
+		{"[2001:db8::1]:8080", "", "2001:db8::1"},
+		{"[fe80::1%eth0]:8080", "", "fe80::1%eth0"},
+		{"[::ffff:192.168.0.1]:8080", "", "192.168.0.1"},
+		{"192.168.0.1", "", "192.168.0.1"},
+		{"invalid:8080", "", ""},
+		{"[2001:db8::1]:8080", "[2001:db8::2]:443", "2001:db8::2"},
+		{"[2001:db8::1]:8080", "2001:db8::3", "2001:db8::3"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = test.remoteAddr
+		if test.xff != "" {
+			req.Header.Set("X-Forwarded-For", test.xff)
+		}
+
+		if ip := GetRealIPAddr(req); ip != test.expected {
+			t.Errorf("%s: Unexpected IP value: \n\t got %v\n\twant %v", test.remoteAddr, ip, test.expected)
+		}
+	}
+}
+
 func TestFormToStruct(t *testing.T) {
 
 	type Test struct {
@@ -250,3 +323,359 @@ func TestFormToStruct(t *testing.T) {
 	}
 
 }
+
+func TestFormToStructErrors(t *testing.T) {
+
+	type Test struct {
+		Int   int     `form:"int"`
+		Int8  int8    `form:"int8"`
+		Uint  uint    `form:"uint"`
+		Uint8 uint16  `form:"uint16"`
+		Float float32 `form:"float"`
+		Bool  bool    `form:"bool"`
+	}
+
+	params := url.Values{}
+	params.Add("int", "abc")
+	params.Add("int8", "128")
+	params.Add("uint", "-1")
+	params.Add("uint16", "65536")
+	params.Add("float", "1e39")
+	params.Add("bool", "yes")
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(params.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	err := FormToStruct(r, &Test{})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an APIError, got %v", err)
+	}
+	if apiErr.Status != http.StatusBadRequest {
+		t.Errorf("unexpected status: got %v want %v", apiErr.Status, http.StatusBadRequest)
+	}
+
+	expected := map[string]string{
+		"int": "invalid", "int8": "out_of_range", "uint": "invalid",
+		"uint16": "out_of_range", "float": "out_of_range", "bool": "invalid",
+	}
+	if len(apiErr.Details) != len(expected) {
+		t.Errorf("unexpected number of field errors: got %v want %v", len(apiErr.Details), len(expected))
+	}
+	for _, detail := range apiErr.Details {
+		if expected[detail.Field] != detail.Code {
+			t.Errorf("unexpected error code for field %q: got %v want %v", detail.Field, detail.Code, expected[detail.Field])
+		}
+		if detail.Value != params.Get(detail.Field) {
+			t.Errorf("unexpected raw value for field %q: got %v want %v", detail.Field, detail.Value, params.Get(detail.Field))
+		}
+	}
+}
+
+func TestFormToStructStrict(t *testing.T) {
+
+	type Test struct {
+		Name string `form:"name"`
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/?name=gopher&admin=true", nil)
+
+	test := Test{}
+	if err := FormToStruct(r, &test); err != nil {
+		t.Errorf("FormToStruct fail! error: %s", err)
+	}
+
+	err := FormToStructStrict(r, &test)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || len(apiErr.Details) != 1 || apiErr.Details[0].Field != "admin" || apiErr.Details[0].Code != "unknown" {
+		t.Errorf("expected an unknown field error for \"admin\", got %v", err)
+	}
+}
+
+type testLevel int
+
+func (l *testLevel) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return errors.New("unknown level")
+	}
+	return nil
+}
+
+func TestFormToStructComplexTypes(t *testing.T) {
+
+	type Address struct {
+		City string `form:"city"`
+		Zip  *int   `form:"zip"`
+	}
+	type Item struct {
+		Name string `form:"name"`
+		Qty  uint8  `form:"qty"`
+	}
+	type Base struct {
+		ID int `form:"id"`
+	}
+	type Test struct {
+		Base
+		Tags     []string      `form:"tags"`
+		Numbers  []int         `form:"numbers"`
+		Page     *int          `form:"page"`
+		Missing  *string       `form:"missing"`
+		Address  Address       `form:"address"`
+		Billing  *Address      `form:"billing"`
+		Items    []Item        `form:"items"`
+		Born     time.Time     `form:"born,layout=2006-01-02"`
+		Created  time.Time     `form:"created"`
+		Timeout  time.Duration `form:"timeout"`
+		Level    testLevel     `form:"level"`
+		Internal string        `form:"-"`
+	}
+
+	params := url.Values{}
+	params.Add("id", "7")
+	params.Add("tags", "a")
+	params.Add("tags", "b")
+	params.Add("numbers[]", "1")
+	params.Add("numbers[]", "2")
+	params.Add("page", "3")
+	params.Add("address.city", "CDMX")
+	params.Add("billing[city]", "GDL")
+	params.Add("billing[zip]", "44100")
+	params.Add("items[0].name", "pen")
+	params.Add("items[0].qty", "2")
+	params.Add("items[1][name]", "book")
+	params.Add("born", "1990-05-17")
+	params.Add("created", "2023-01-02T15:04:05Z")
+	params.Add("timeout", "1m30s")
+	params.Add("level", "high")
+	params.Add("-", "ignored")
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(params.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	test := Test{}
+	if err := FormToStruct(r, &test); err != nil {
+		t.Fatalf("FormToStruct fail! error: %s", err)
+	}
+
+	if test.ID != 7 {
+		t.Errorf("FormToStruct Unexpected value for embedded field \"ID\":\nGot  %v\nWant 7", test.ID)
+	}
+	if !reflect.DeepEqual(test.Tags, []string{"a", "b"}) || !reflect.DeepEqual(test.Numbers, []int{1, 2}) {
+		t.Errorf("FormToStruct Unexpected slice values:\nGot  %v %v\nWant [a b] [1 2]", test.Tags, test.Numbers)
+	}
+	if test.Page == nil || *test.Page != 3 || test.Missing != nil {
+		t.Errorf("FormToStruct Unexpected pointer values:\nGot  %v %v\nWant 3 <nil>", test.Page, test.Missing)
+	}
+	if test.Address.City != "CDMX" || test.Address.Zip != nil {
+		t.Errorf("FormToStruct Unexpected value for field \"Address\":\nGot  %+v", test.Address)
+	}
+	if test.Billing == nil || test.Billing.City != "GDL" || test.Billing.Zip == nil || *test.Billing.Zip != 44100 {
+		t.Errorf("FormToStruct Unexpected value for field \"Billing\":\nGot  %+v", test.Billing)
+	}
+	if !reflect.DeepEqual(test.Items, []Item{{Name: "pen", Qty: 2}, {Name: "book"}}) {
+		t.Errorf("FormToStruct Unexpected value for field \"Items\":\nGot  %+v", test.Items)
+	}
+	if test.Born.Format("2006-01-02") != "1990-05-17" || test.Created.Unix() != 1672671845 {
+		t.Errorf("FormToStruct Unexpected time values:\nGot  %v %v", test.Born, test.Created)
+	}
+	if test.Timeout != 90*time.Second || test.Level != 2 {
+		t.Errorf("FormToStruct Unexpected values:\nGot  %v %v\nWant 1m30s 2", test.Timeout, test.Level)
+	}
+	if test.Internal != "" {
+		t.Errorf("FormToStruct assigned an ignored field: %v", test.Internal)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/?level=medium&numbers=1&numbers=x", nil)
+	err := FormToStruct(r, &Test{})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || len(apiErr.Details) != 2 {
+		t.Errorf("expected two field errors, got %v", err)
+	}
+}
+
+func TestFormToStructDefaultAndRequired(t *testing.T) {
+
+	type Test struct {
+		Page    int      `form:"page,default=1"`
+		Size    *int     `form:"size,default=20"`
+		Sort    []string `form:"sort,default=id"`
+		Email   string   `form:"email,required"`
+		Token   *string  `form:"token,required"`
+		Keyword string   `form:"q,required,default=ignored"`
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/?email=gopher@golang.org&token=abc&q=go", nil)
+
+	test := Test{}
+	if err := FormToStruct(r, &test); err != nil {
+		t.Fatalf("FormToStruct fail! error: %s", err)
+	}
+	if test.Page != 1 || test.Size == nil || *test.Size != 20 || !reflect.DeepEqual(test.Sort, []string{"id"}) {
+		t.Errorf("FormToStruct Unexpected default values:\nGot  %v %v %v\nWant 1 20 [id]", test.Page, test.Size, test.Sort)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/?page=5&email=", nil)
+
+	test = Test{}
+	err := FormToStruct(r, &test)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an APIError, got %v", err)
+	}
+	fields := []string{}
+	for _, detail := range apiErr.Details {
+		if detail.Code != "required" {
+			t.Errorf("unexpected error code for field %q: got %v want required", detail.Field, detail.Code)
+		}
+		fields = append(fields, detail.Field)
+	}
+	if !reflect.DeepEqual(fields, []string{"email", "token", "q"}) {
+		t.Errorf("unexpected required fields:\ngot  %v\nwant [email token q]", fields)
+	}
+	if test.Page != 5 {
+		t.Errorf("FormToStruct Unexpected value for field \"Page\":\nGot  %v\nWant 5", test.Page)
+	}
+}
+
+func TestBindRequest(t *testing.T) {
+
+	type Test struct {
+		ID     uint     `path:"id"`
+		Page   int      `query:"page,default=1"`
+		Sort   []string `query:"sort"`
+		Name   string   `form:"name"`
+		Token  string   `header:"authorization,required"`
+		Locale string   `header:"Accept-Language"`
+	}
+
+	params := url.Values{}
+	params.Add("name", "gopher")
+	params.Add("page", "9")
+
+	r := httptest.NewRequest(http.MethodPost, "/users/42?sort=name&sort=-id", strings.NewReader(params.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("Authorization", "Bearer 12345")
+	r.Header.Set("Accept-Language", "es-MX")
+	r = mux.SetURLVars(r, map[string]string{"id": "42"})
+
+	test := Test{}
+	if err := BindRequest(r, &test); err != nil {
+		t.Fatalf("BindRequest fail! error: %s", err)
+	}
+
+	expected := Test{ID: 42, Page: 1, Sort: []string{"name", "-id"}, Name: "gopher", Token: "Bearer 12345", Locale: "es-MX"}
+	if !reflect.DeepEqual(test, expected) {
+		t.Errorf("BindRequest Unexpected values:\nGot  %+v\nWant %+v", test, expected)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/users/abc", nil)
+	r = mux.SetURLVars(r, map[string]string{"id": "abc"})
+
+	err := BindRequest(r, &Test{})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || len(apiErr.Details) != 2 {
+		t.Errorf("expected path and header field errors, got %v", err)
+	}
+}
+
+type benchmarkForm struct {
+	ID      int       `form:"id"`
+	Name    string    `form:"name,required"`
+	Email   string    `form:"email"`
+	Page    int       `form:"page,default=1"`
+	Size    *int      `form:"size,default=20"`
+	Price   float64   `form:"price"`
+	Active  bool      `form:"active"`
+	Tags    []string  `form:"tags"`
+	Created time.Time `form:"created"`
+	Address struct {
+		Street string `form:"street"`
+		City   string `form:"city"`
+		Zip    string `form:"zip"`
+	} `form:"address"`
+}
+
+func newBenchmarkFormRequest() *http.Request {
+	params := url.Values{}
+	params.Add("id", "100")
+	params.Add("name", "gopher")
+	params.Add("email", "gopher@golang.org")
+	params.Add("price", "9.99")
+	params.Add("active", "true")
+	params.Add("tags", "a")
+	params.Add("tags", "b")
+	params.Add("created", "2023-01-02T15:04:05Z")
+	params.Add("address.street", "Reforma 222")
+	params.Add("address.city", "CDMX")
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(params.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.ParseForm()
+	return r
+}
+
+func BenchmarkFormToStruct(b *testing.B) {
+	r := newBenchmarkFormRequest()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		model := benchmarkForm{}
+		if err := FormToStruct(r, &model); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFormToStructUncached(b *testing.B) {
+	r := newBenchmarkFormRequest()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		formPlans.Range(func(key, value interface{}) bool {
+			formPlans.Delete(key)
+			return true
+		})
+		model := benchmarkForm{}
+		if err := FormToStruct(r, &model); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFormToStructParallel(b *testing.B) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		r := newBenchmarkFormRequest()
+		for pb.Next() {
+			model := benchmarkForm{}
+			if err := FormToStruct(r, &model); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkBindRequest(b *testing.B) {
+	r := newBenchmarkFormRequest()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		model := benchmarkForm{}
+		if err := BindRequest(r, &model); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
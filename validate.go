@@ -0,0 +1,207 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+var validateRegexps sync.Map
+
+/*
+Return the compiled expression of a "regex" validation rule, expressions are
+compiled once and reused
+*/
+func validateRegexp(expr string) (*regexp.Regexp, error) {
+	if re, ok := validateRegexps.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	validateRegexps.Store(expr, re)
+	return re, nil
+}
+
+/*
+Split the rules of a "validate" tag. The "regex" rule takes the rest of the tag so
+expressions may contain commas
+*/
+func validateRules(tag string) []string {
+	rules := []string{}
+	for tag != "" {
+		if strings.HasPrefix(tag, "regex=") {
+			return append(rules, tag)
+		}
+		rule, rest, _ := strings.Cut(tag, ",")
+		if rule = strings.TrimSpace(rule); rule != "" {
+			rules = append(rules, rule)
+		}
+		tag = rest
+	}
+	return rules
+}
+
+/*
+Return the name of the field used in error details, taken from the json tag when present
+*/
+func fieldName(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return field.Name
+}
+
+/*
+Return the length of strings (in characters), slices and maps or the value of numbers
+used by the min, max and len rules
+*/
+func validateSize(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+func validateRule(v reflect.Value, rule string) (string, string, bool) {
+	name, param, _ := strings.Cut(rule, "=")
+
+	if name == "required" {
+		if v.IsZero() {
+			return name, "is required", false
+		}
+		return "", "", true
+	}
+
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", "", true
+		}
+		v = v.Elem()
+	}
+
+	switch name {
+	case "min", "max", "len":
+		limit, err := strconv.ParseFloat(param, 64)
+		size, ok := validateSize(v)
+		if err != nil || !ok {
+			return name, "has an invalid \"" + rule + "\" rule", false
+		}
+		unit := ""
+		switch v.Kind() {
+		case reflect.String:
+			unit = " characters"
+		case reflect.Slice, reflect.Array, reflect.Map:
+			unit = " elements"
+		}
+		switch {
+		case name == "min" && size < limit && unit == "":
+			return name, "must be greater than or equal to " + param, false
+		case name == "min" && size < limit:
+			return name, "must have at least " + param + unit, false
+		case name == "max" && size > limit && unit == "":
+			return name, "must be less than or equal to " + param, false
+		case name == "max" && size > limit:
+			return name, "must have at most " + param + unit, false
+		case name == "len" && size != limit:
+			return name, "must have exactly " + param + unit, false
+		}
+	case "email":
+		if v.Kind() == reflect.String && v.String() != "" {
+			addr, err := mail.ParseAddress(v.String())
+			if err != nil || addr.Address != v.String() {
+				return name, "must be a valid email address", false
+			}
+		}
+	case "regex":
+		re, err := validateRegexp(param)
+		if err != nil || v.Kind() != reflect.String {
+			return name, "has an invalid \"regex\" rule", false
+		}
+		if v.String() != "" && !re.MatchString(v.String()) {
+			return name, "has an invalid format", false
+		}
+	case "oneof":
+		value := fmt.Sprint(v.Interface())
+		for _, option := range strings.Fields(param) {
+			if value == option {
+				return "", "", true
+			}
+		}
+		return name, "must be one of [" + param + "]", false
+	}
+
+	return "", "", true
+}
+
+func validateStruct(v reflect.Value, prefix string, details []FieldError) []FieldError {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := prefix + fieldName(field)
+		value := v.Field(i)
+
+		for _, rule := range validateRules(field.Tag.Get("validate")) {
+			if code, message, ok := validateRule(value, rule); !ok {
+				details = append(details, FieldError{Field: name, Code: code, Message: name + " " + message})
+				break
+			}
+		}
+
+		for value.Kind() == reflect.Ptr && !value.IsNil() {
+			value = value.Elem()
+		}
+		if value.Kind() == reflect.Struct {
+			if field.Anonymous {
+				details = validateStruct(value, prefix, details)
+			} else {
+				details = validateStruct(value, name+".", details)
+			}
+		}
+	}
+	return details
+}
+
+/*
+Validate the struct fields using their "validate" tag rules: required, min, max, len,
+regex, email and oneof. Returns an APIError with status 422 and a detail per failing
+field, or nil when the struct is valid
+*/
+func Validate(model interface{}) error {
+	v := reflect.ValueOf(model)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	details := validateStruct(v, "", nil)
+	if len(details) == 0 {
+		return nil
+	}
+
+	return NewAPIError(http.StatusUnprocessableEntity, "validation_failed", "invalid request data").WithDetails(details...)
+}
@@ -0,0 +1,58 @@
+package rest
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	type Address struct {
+		City string `json:"city" validate:"required"`
+	}
+	type Test struct {
+		Name    string   `json:"name" validate:"required,min=3,max=10"`
+		Email   string   `json:"email" validate:"required,email"`
+		Age     int      `json:"age" validate:"min=18,max=99"`
+		Code    string   `json:"code" validate:"len=4"`
+		Role    string   `json:"role" validate:"oneof=admin user"`
+		Slug    string   `json:"slug" validate:"regex=^[a-z]{1,3}(-[a-z]+)*$"`
+		Tags    []string `json:"tags" validate:"max=2"`
+		Address *Address `json:"address"`
+	}
+
+	valid := Test{
+		Name: "Gopher", Email: "gopher@golang.org", Age: 30, Code: "ABCD",
+		Role: "admin", Slug: "go-lang", Tags: []string{"a"}, Address: &Address{City: "CDMX"},
+	}
+	if err := Validate(&valid); err != nil {
+		t.Errorf("Validate fail! error: %s", err)
+	}
+
+	invalid := Test{
+		Name: "Go", Email: "not-an-email", Age: 10, Code: "ABC",
+		Role: "root", Slug: "Go Lang", Tags: []string{"a", "b", "c"}, Address: &Address{},
+	}
+	err := Validate(invalid)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an APIError, got %v", err)
+	}
+
+	fields := []string{}
+	codes := []string{}
+	for _, detail := range apiErr.Details {
+		fields = append(fields, detail.Field)
+		codes = append(codes, detail.Code)
+	}
+	expectedFields := []string{"name", "email", "age", "code", "role", "slug", "tags", "address.city"}
+	expectedCodes := []string{"min", "email", "min", "len", "oneof", "regex", "max", "required"}
+	if !reflect.DeepEqual(fields, expectedFields) || !reflect.DeepEqual(codes, expectedCodes) {
+		t.Errorf("unexpected validation details:\ngot  %v %v\nwant %v %v", fields, codes, expectedFields, expectedCodes)
+	}
+
+	if err := Validate(&Test{}); err == nil {
+		t.Errorf("expected required fields errors")
+	}
+}